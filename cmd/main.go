@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"io"
 	"log"
 	"net/http"
@@ -14,9 +16,12 @@ import (
 
 	"schedulerservice/internal/api"
 	"schedulerservice/internal/auth"
+	"schedulerservice/internal/config"
 	"schedulerservice/internal/db"
 	"schedulerservice/internal/jobs"
 	"schedulerservice/internal/kafka"
+	"schedulerservice/internal/leader"
+	"schedulerservice/internal/logging"
 	"schedulerservice/internal/metrics"
 
 	"github.com/joho/godotenv"
@@ -24,50 +29,140 @@ import (
 
 func main() {
 	godotenv.Load()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	cfg, args, err := config.Load(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Could not load configuration: %s\n", err.Error())
+	}
+	if len(args) > 0 {
+		log.Fatalf("Unexpected arguments: %v\n", args)
+	}
+	logging.SetLevel(logging.Level(cfg.LogLevel))
+
 	go func() {
-		registerService()
+		if err := registerService(cfg); err != nil {
+			log.Printf("[ERROR] Failed to register service: %v", err)
+		}
 	}()
 	metrics.Init()
-	jm := jobs.GetJobManager()
+
+	store, err := db.OpenConfig(cfg.Database)
+	if err != nil {
+		log.Fatalf("Could not open database: %s\n", err.Error())
+	}
+	if err := store.Migrate(); err != nil {
+		log.Fatalf("Could not migrate database: %s\n", err.Error())
+	}
+	jm := jobs.NewJobManager(store)
+	events := kafka.NewPublisher(cfg.Kafka)
+	jm.SetEventPublisher(events)
+	if cfg.Kafka.Enabled() {
+		jm.SetExecutor(jobs.TargetKafka, kafka.NewExecutor(cfg.Kafka))
+	}
+	if err := jm.LoadJobs(); err != nil {
+		log.Printf("[ERROR] Failed to load jobs: %v", err)
+	}
+
+	elector, err := leader.NewElectorFromConfig(store.DB(), store.Dialect(), cfg.Leader)
+	if err != nil {
+		log.Fatalf("Could not set up leader election: %s\n", err.Error())
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go kafka.InitKafka(ctx, jm)
+	go elector.Run(ctx, jm.StartScheduling, jm.StopScheduling)
+	go jm.SyncJobs(ctx, cfg.Leader.SyncInterval.Std())
+	kafkaDone := make(chan struct{})
+	go func() {
+		defer close(kafkaDone)
+		kafka.InitKafka(ctx, cfg.Kafka, jm)
+	}()
 
-	router := api.NewRouter()
+	server := &http.Server{
+		Addr:         cfg.Addr(),
+		Handler:      api.NewRouter(jm, elector),
+		ReadTimeout:  cfg.Timeouts.HTTPRead.Std(),
+		WriteTimeout: cfg.Timeouts.HTTPWrite.Std(),
+		IdleTimeout:  cfg.Timeouts.HTTPIdle.Std(),
+	}
 
-	gracefulShutdown(cancel, jm)
+	shutdownDone := gracefulShutdown(cancel, cfg, server, kafkaDone, jm, events, elector, store)
 
-	log.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", router); err != nil {
+	log.Printf("Starting server on %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Could not start server: %s\n", err.Error())
 	}
+	<-shutdownDone
 }
 
-// gracefulShutdown listens for OS signals and cancels the context to allow for graceful shutdown.
-func gracefulShutdown(cancel context.CancelFunc, jm *jobs.JobManager) {
+// serverShutdownTimeout bounds the requests still being served once the jobs have been drained
+const serverShutdownTimeout = 5 * time.Second
+
+// gracefulShutdown waits for an OS signal and then shuts the service down in order: it
+// refuses new runs, stops taking input from the registry, the API and Kafka, waits for the running jobs up to the
+// shutdown timeout, cancelling the ones left, flushes the events, gives up leadership, stops
+// the HTTP server once its last requests are served and finally closes the store. The
+// returned channel is closed once it is done
+func gracefulShutdown(cancel context.CancelFunc, cfg *config.Config, server *http.Server, kafkaDone <-chan struct{},
+	jm *jobs.JobManager, events *kafka.Publisher, elector *leader.Elector, store jobs.Store) <-chan struct{} {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-	defer signal.Stop(sig)
+	done := make(chan struct{})
 
 	go func() {
-			<-sig
-			log.Println("shutdown signal received, cancelling background work")
-			cancel()
-			
-			if err := jm.ShutDown(); err != nil {
-				log.Printf("Error during JobManager shutdown: %v", err)
-			}
-			if err := db.GetDB().Close(); err != nil {
-				log.Printf("Error closing database connection: %v", err)
-			}
-			if err := deregisterService(); err != nil {
-				log.Printf("Error deregistering service: %v", err)
-			}
-
-			log.Println("shutdown complete")
-			os.Exit(0)
+		defer close(done)
+		<-sig
+		// A second signal skips the rest of the shutdown
+		signal.Stop(sig)
+		log.Println("shutdown signal received, draining running jobs")
+		// Refuses new runs and changes to jobs while the service deregisters
+		jm.Drain()
+
+		if err := deregisterService(cfg); err != nil {
+			log.Printf("Error deregistering service: %v", err)
+		}
+		// Stops the Kafka consumer and the leader election, which stops scheduling
+		cancel()
+
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown.Std())
+		if err := jm.ShutDown(drainCtx); err != nil {
+			log.Printf("Error during JobManager shutdown: %v", err)
+		}
+		select {
+		case <-kafkaDone:
+		case <-drainCtx.Done():
+			log.Printf("Kafka consumer did not stop before the shutdown timeout")
+		}
+		drainCancel()
+
+		eventsCtx, eventsCancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		if err := events.Close(eventsCtx); err != nil {
+			log.Printf("Error flushing events: %v", err)
+		}
+		eventsCancel()
+		resignCtx, resignCancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		if err := elector.Resign(resignCtx); err != nil {
+			log.Printf("Error giving up leadership: %v", err)
+		}
+		resignCancel()
+		serverCtx, serverCancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		if err := server.Shutdown(serverCtx); err != nil {
+			log.Printf("Error shutting down HTTP server: %v", err)
+		}
+		serverCancel()
+		if err := store.Close(); err != nil {
+			log.Printf("Error closing database connection: %v", err)
+		}
+
+		log.Println("shutdown complete")
 	}()
+	return done
 }
 
 type Service struct {
@@ -76,39 +171,36 @@ type Service struct {
 	HealthURL string `json:"health_url"`
 }
 
-// registerService registers the service with the service registry
-func registerService() error {
-	registryEndpoint := os.Getenv("SERVICE_REGISTRY_URL") + "/register"
-	req, callErr := http.NewRequest(http.MethodPost, registryEndpoint, nil)
-	if callErr != nil {
-		return callErr
+// registerService registers the service with the service registry, if one is configured
+func registerService(cfg *config.Config) error {
+	if cfg.RegistryURL == "" {
+		log.Println("No service registry configured, skipping registration")
+		return nil
 	}
-	auth.AddAPIKeyToRequest(req)
-	DefineService(req)
-	client := &http.Client{}
+	registryEndpoint := cfg.RegistryURL + "/register"
+	client := &http.Client{Timeout: cfg.Timeouts.Registry.Std()}
 	var retries = 3
-	resp, callErr := client.Do(req)
+	resp, callErr := postService(client, registryEndpoint)
 	for callErr != nil && retries > 0 {
 		log.Printf("Failed to register service, retrying... (%d retries left)", retries)
 		retries--
 		time.Sleep(10 * time.Second)
-		resp, callErr = client.Do(req)
+		resp, callErr = postService(client, registryEndpoint)
+	}
+	if callErr != nil {
+		return callErr
 	}
 	defer resp.Body.Close()
 	return nil
 }
 
-// deregisterService deregisters the service from the service registry
-func deregisterService() error {
-	registryEndpoint := os.Getenv("SERVICE_REGISTRY_URL") + "/deregister"
-	req, callErr := http.NewRequest(http.MethodPost, registryEndpoint, nil)
-	if callErr != nil {
-		return callErr
+// deregisterService deregisters the service from the service registry, if one is configured
+func deregisterService(cfg *config.Config) error {
+	if cfg.RegistryURL == "" {
+		return nil
 	}
-	auth.AddAPIKeyToRequest(req)
-	DefineService(req)
-	client := &http.Client{}
-	resp, callErr := client.Do(req)
+	client := &http.Client{Timeout: cfg.Timeouts.Registry.Std()}
+	resp, callErr := postService(client, cfg.RegistryURL+"/deregister")
 	if callErr != nil {
 		return callErr
 	}
@@ -116,6 +208,18 @@ func deregisterService() error {
 	return nil
 }
 
+// postService sends the service details to a registry endpoint. The request is built
+// for every call, as its body cannot be sent twice
+func postService(client *http.Client, endpoint string) (*http.Response, error) {
+	req, callErr := http.NewRequest(http.MethodPost, endpoint, nil)
+	if callErr != nil {
+		return nil, callErr
+	}
+	auth.AddAPIKeyToRequest(req)
+	DefineService(req)
+	return client.Do(req)
+}
+
 // DefineService defines the service details in the request body
 func DefineService(req *http.Request) error {
 	var s = &Service{
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"schedulerservice/internal/config"
+	"schedulerservice/internal/db"
+)
+
+// runMigrate handles the migrate subcommand, which prints the status of the schema
+// migrations, applying the pending ones first with "migrate up". It returns the exit code
+func runMigrate(args []string) int {
+	cfg, args, err := config.Load("migrate", args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		log.Printf("[ERROR] Could not load configuration: %v", err)
+		return 2
+	}
+	if len(args) > 1 || (len(args) == 1 && args[0] != "up" && args[0] != "status") {
+		fmt.Fprintln(os.Stderr, "usage: schedulerservice migrate [flags] [status|up]")
+		return 2
+	}
+
+	store, err := db.OpenConfig(cfg.Database)
+	if err != nil {
+		log.Printf("[ERROR] Could not open database: %v", err)
+		return 1
+	}
+	defer store.Close()
+
+	if len(args) == 1 && args[0] == "up" {
+		if err := store.Migrate(); err != nil {
+			log.Printf("[ERROR] Could not migrate database: %v", err)
+			return 1
+		}
+	}
+
+	statuses, err := store.MigrationStatus()
+	if err != nil {
+		log.Printf("[ERROR] Could not read migration status: %v", err)
+		return 1
+	}
+
+	pending := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+		} else {
+			pending++
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	w.Flush()
+	fmt.Printf("\n%s database, %d pending migration(s)\n", store.Dialect(), pending)
+	return 0
+}
@@ -4,6 +4,8 @@ import (
 	"log"
 	"net/http"
 	"time"
+
+	"schedulerservice/internal/jobs"
 )
 
 func loggingMiddleware(next http.Handler) http.Handler {
@@ -17,3 +19,17 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		log.Printf("Completed %s %s in %v", r.Method, r.URL.Path, duration)
 	})
 }
+
+// drainingMiddleware rejects the requests that change jobs once the job manager is
+// shutting down, while reads such as the healthcheck and metrics keep being served
+func drainingMiddleware(jobManager *jobs.JobManager, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && jobManager.ShuttingDown() {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "service is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,40 +2,73 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"schedulerservice/internal/auth"
 	"schedulerservice/internal/jobs"
+	"schedulerservice/internal/leader"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var jobManager = jobs.GetJobManager()
+// handler serves the API on top of the job manager and the leader elector
+type handler struct {
+	jobManager *jobs.JobManager
+	elector    *leader.Elector
+}
 
-func NewRouter() http.Handler {
+func NewRouter(jobManager *jobs.JobManager, elector *leader.Elector) http.Handler {
+	h := &handler{jobManager: jobManager, elector: elector}
 	mux := http.NewServeMux()
-	mux.HandleFunc("/healthcheck", healthHandler)
-	mux.HandleFunc("/jobs/register", jobRegisterHandler)
-	mux.HandleFunc("/jobs/deregister", jobDeregisterHandler)
-	mux.HandleFunc("/jobs/list", jobListHandler)
+	mux.HandleFunc("/healthcheck", h.healthHandler)
+	mux.HandleFunc("/jobs/register", h.jobRegisterHandler)
+	mux.HandleFunc("/jobs/deregister", h.jobDeregisterHandler)
+	mux.HandleFunc("/jobs/list", h.jobListHandler)
+	mux.HandleFunc("/jobs/validate", h.jobValidateHandler)
+	mux.HandleFunc("/jobs/{name}", h.jobHandler)
+	mux.HandleFunc("/jobs/{name}/executions", h.jobExecutionsHandler)
+	mux.HandleFunc("/jobs/{name}/run", h.jobRunHandler)
+	mux.HandleFunc("/jobs/{name}/pause", h.jobPauseHandler)
+	mux.HandleFunc("/jobs/{name}/resume", h.jobResumeHandler)
 	mux.Handle("/metrics", promhttp.Handler())
 
-	return loggingMiddleware(auth.ValidateAPIKey(mux))
+	return loggingMiddleware(auth.ValidateAPIKey(drainingMiddleware(jobManager, mux)))
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
+// healthHandler reports the health of the service along with the identity of this
+// instance and of the current leader, which is the instance scheduling jobs
+func (h *handler) healthHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+
+	leaderID, err := h.elector.Leader(r.Context())
+	if err != nil {
+		log.Printf("[WARN] Failed to look up the leader: %v", err)
+	}
+	status := "ok"
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "ok",
-		"service": "schedulerservice",
+	if h.jobManager.ShuttingDown() {
+		status = "shutting_down"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":    status,
+		"service":   "schedulerservice",
+		"instance":  h.elector.ID(),
+		"leader":    leaderID,
+		"is_leader": h.elector.IsLeader(),
 	})
 }
 
-func jobRegisterHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handler) jobRegisterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -47,8 +80,9 @@ func jobRegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := jobManager.Register(job); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	registered, err := h.jobManager.Register(job)
+	if err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
 		return
 	}
 
@@ -58,11 +92,11 @@ func jobRegisterHandler(w http.ResponseWriter, r *http.Request) {
 		Status:  "registered",
 		Name:    job.Name,
 		Message: "job registered successfully",
-		Job:     job,
+		Job:     registered,
 	})
 }
 
-func jobDeregisterHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handler) jobDeregisterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -74,8 +108,8 @@ func jobDeregisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := jobManager.Deregister(req.Name); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.jobManager.Deregister(req.Name); err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
 		return
 	}
 
@@ -88,7 +122,7 @@ func jobDeregisterHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func jobListHandler(w http.ResponseWriter, r *http.Request) {
+func (h *handler) jobListHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -98,6 +132,250 @@ func jobListHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(jobs.JobListResponse{
 		Status:  "success",
 		Message: "job list retrieved successfully",
-		Jobs:    jobManager.List(),
+		Jobs:    h.jobManager.List(),
+	})
+}
+
+func (h *handler) jobValidateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req jobs.ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	preview, err := jobs.PreviewSchedule(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs.ScheduleResponse{
+		Status:   "valid",
+		Message:  "schedule parsed successfully",
+		Schedule: preview,
+	})
+}
+
+func (h *handler) jobHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.jobGetHandler(w, r)
+	case http.MethodPut:
+		h.jobUpdateHandler(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) jobGetHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	job, err := h.jobManager.Get(name)
+	if err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs.JobDetailResponse{
+		Status:  "success",
+		Name:    name,
+		Message: "job retrieved successfully",
+		Job:     job,
+	})
+}
+
+func (h *handler) jobUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	job, err := jobs.DecodeUpdate(data)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if job.Name != "" && job.Name != name {
+		http.Error(w, "job name cannot be changed", http.StatusBadRequest)
+		return
+	}
+	job.Name = name
+
+	updated, err := h.jobManager.Update(job)
+	if err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs.JobResponse{
+		Status:  "updated",
+		Name:    name,
+		Message: "job updated successfully",
+		Job:     updated,
+	})
+}
+
+func (h *handler) jobRunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := h.jobManager.Trigger(name); err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(jobs.JobResponse{
+		Status:  "triggered",
+		Name:    name,
+		Message: "job run started",
+	})
+}
+
+func (h *handler) jobPauseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := h.jobManager.Pause(name); err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs.JobResponse{
+		Status:  "paused",
+		Name:    name,
+		Message: "job paused successfully",
 	})
 }
+
+func (h *handler) jobResumeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := h.jobManager.Resume(name); err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs.JobResponse{
+		Status:  "resumed",
+		Name:    name,
+		Message: "job resumed successfully",
+	})
+}
+
+// jobErrorStatus maps an error returned by the job manager to an HTTP status code
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, jobs.ErrJobExists):
+		return http.StatusConflict
+	case errors.Is(err, jobs.ErrInvalidJob):
+		return http.StatusBadRequest
+	case errors.Is(err, jobs.ErrShuttingDown):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *handler) jobExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	filter, err := parseExecutionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.jobManager.Get(name); err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+	executions, total, err := h.jobManager.Executions(name, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs.ExecutionListResponse{
+		Status:     "success",
+		Name:       name,
+		Message:    "job executions retrieved successfully",
+		Total:      total,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+		Executions: executions,
+	})
+}
+
+// parseExecutionFilter reads the status, trigger, limit and offset query parameters.
+// Statuses may be repeated or comma separated, e.g. ?status=failed,running
+func parseExecutionFilter(r *http.Request) (jobs.ExecutionFilter, error) {
+	query := r.URL.Query()
+	filter := jobs.ExecutionFilter{Limit: 50}
+
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			s := jobs.ExecutionStatus(strings.TrimSpace(status))
+			if s == "" {
+				continue
+			}
+			if !s.Valid() {
+				return filter, fmt.Errorf("invalid status %q", status)
+			}
+			filter.Statuses = append(filter.Statuses, s)
+		}
+	}
+
+	if trigger := jobs.Trigger(query.Get("trigger")); trigger != "" {
+		if !trigger.Valid() {
+			return filter, fmt.Errorf("invalid trigger %q", trigger)
+		}
+		filter.Trigger = trigger
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > 500 {
+			return filter, fmt.Errorf("limit must be between 1 and 500")
+		}
+		filter.Limit = n
+	}
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("offset must be a non-negative integer")
+		}
+		filter.Offset = n
+	}
+	return filter, nil
+}
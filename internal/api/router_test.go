@@ -0,0 +1,473 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"schedulerservice/internal/auth"
+	"schedulerservice/internal/jobs"
+	"schedulerservice/internal/leader"
+)
+
+const testAPIKey = "test-key"
+
+// newTestRouter serves the API on top of a job manager kept in memory
+func newTestRouter(t *testing.T) (http.Handler, *jobs.JobManager) {
+	t.Helper()
+	t.Setenv(auth.APIKeyEnv, testAPIKey)
+	jm := jobs.NewJobManager(jobs.NewMemoryStore())
+	return NewRouter(jm, leader.Standalone("test")), jm
+}
+
+// serve sends a request with the API key to router and returns the recorded response
+func serve(t *testing.T, router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(auth.APIKeyHeader, testAPIKey)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode reads the JSON body of a response into dest
+func decode(t *testing.T, rec *httptest.ResponseRecorder, dest any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), dest); err != nil {
+		t.Fatalf("invalid response body %q: %v", rec.Body.String(), err)
+	}
+}
+
+// registerJob registers a job that never runs on its own, calling endpoint
+func registerJob(t *testing.T, jm *jobs.JobManager, name, endpoint string) {
+	t.Helper()
+	if _, err := jm.Register(jobs.Job{Name: name, Cron: "0 0 1 1 *", Endpoint: endpoint}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+}
+
+// waitForManualRun fails the test if a manual run of a job has not succeeded within a few seconds
+func waitForManualRun(t *testing.T, jm *jobs.JobManager, name string) {
+	t.Helper()
+	manual := jobs.ExecutionFilter{Trigger: jobs.TriggerManual, Statuses: []jobs.ExecutionStatus{jobs.ExecutionSucceeded}}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, total, _ := jm.Executions(name, manual); total == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the manual run of %s", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobExecutionsHandler(t *testing.T) {
+	router, jm := newTestRouter(t)
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	registerJob(t, jm, "ping", server.URL)
+	registerJob(t, jm, "idle", server.URL)
+
+	if err := jm.Trigger("ping"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	waitForManualRun(t, jm, "ping")
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		total  int
+	}{
+		{name: "history", path: "/jobs/ping/executions", status: http.StatusOK, total: 1},
+		{name: "filtered", path: "/jobs/ping/executions?status=failed,skipped", status: http.StatusOK, total: 0},
+		{name: "no runs", path: "/jobs/idle/executions", status: http.StatusOK, total: 0},
+		{name: "unknown job", path: "/jobs/missing/executions", status: http.StatusNotFound},
+		{name: "invalid status", path: "/jobs/ping/executions?status=done", status: http.StatusBadRequest},
+		{name: "invalid limit", path: "/jobs/ping/executions?limit=1000", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, router, http.MethodGet, tt.path, "")
+			if rec.Code != tt.status {
+				t.Fatalf("GET %s returned %d, want %d", tt.path, rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp jobs.ExecutionListResponse
+			decode(t, rec, &resp)
+			if resp.Total != tt.total || len(resp.Executions) != tt.total {
+				t.Errorf("GET %s returned %d of %d executions, want %d", tt.path, len(resp.Executions), resp.Total, tt.total)
+			}
+		})
+	}
+}
+
+func TestJobPauseResumeHandlers(t *testing.T) {
+	router, jm := newTestRouter(t)
+	registerJob(t, jm, "ping", "http://localhost:3000/ping")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		// state is the status reported in the response
+		state  string
+		paused bool
+	}{
+		{name: "pause", method: http.MethodPost, path: "/jobs/ping/pause", status: http.StatusOK, state: "paused", paused: true},
+		{name: "resume", method: http.MethodPost, path: "/jobs/ping/resume", status: http.StatusOK, state: "resumed"},
+		{name: "pause unknown job", method: http.MethodPost, path: "/jobs/missing/pause", status: http.StatusNotFound},
+		{name: "resume unknown job", method: http.MethodPost, path: "/jobs/missing/resume", status: http.StatusNotFound},
+		{name: "pause with GET", method: http.MethodGet, path: "/jobs/ping/pause", status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, router, tt.method, tt.path, "")
+			if rec.Code != tt.status {
+				t.Fatalf("%s %s returned %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp jobs.JobResponse
+			decode(t, rec, &resp)
+			if resp.Name != "ping" || resp.Status != tt.state {
+				t.Errorf("%s %s returned %+v, want status %s", tt.method, tt.path, resp, tt.state)
+			}
+			if job, _ := jm.Get("ping"); job.Paused != tt.paused {
+				t.Errorf("job is paused: %v, want %v", job.Paused, tt.paused)
+			}
+		})
+	}
+}
+
+func TestJobUpdateHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		check  func(t *testing.T, job jobs.Job)
+	}{
+		{
+			name: "cron", path: "/jobs/ping", body: `{"cron":"0 6 * * *"}`, status: http.StatusOK,
+			check: func(t *testing.T, job jobs.Job) {
+				if job.Cron != "0 6 * * *" || job.Headers["X-Token"] != "abc" {
+					t.Errorf("updated job is %+v, want the new cron and the other fields kept", job)
+				}
+			},
+		},
+		{
+			name: "same name in the body", path: "/jobs/ping", body: `{"name":"ping","method":"post"}`, status: http.StatusOK,
+			check: func(t *testing.T, job jobs.Job) {
+				if job.Method != http.MethodPost {
+					t.Errorf("updated job has method %s, want POST", job.Method)
+				}
+			},
+		},
+		{
+			name: "clear headers", path: "/jobs/ping", body: `{"headers":null}`, status: http.StatusOK,
+			check: func(t *testing.T, job jobs.Job) {
+				if len(job.Headers) != 0 {
+					t.Errorf("updated job has headers %v, want none", job.Headers)
+				}
+			},
+		},
+		{name: "rename", path: "/jobs/ping", body: `{"name":"pong"}`, status: http.StatusBadRequest},
+		{name: "invalid body", path: "/jobs/ping", body: `{"cron":`, status: http.StatusBadRequest},
+		{name: "invalid cron", path: "/jobs/ping", body: `{"cron":"every day"}`, status: http.StatusBadRequest},
+		{name: "unknown job", path: "/jobs/missing", body: `{"cron":"0 6 * * *"}`, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, jm := newTestRouter(t)
+			job := jobs.Job{Name: "ping", Cron: "0 0 1 1 *", Endpoint: "http://localhost:3000/ping", Headers: map[string]string{"X-Token": "abc"}}
+			if _, err := jm.Register(job); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+
+			rec := serve(t, router, http.MethodPut, tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("PUT %s %s returned %d, want %d: %s", tt.path, tt.body, rec.Code, tt.status, rec.Body.String())
+			}
+			stored, err := jm.Get("ping")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if tt.status != http.StatusOK {
+				if stored.Cron != job.Cron || stored.Endpoint != job.Endpoint {
+					t.Errorf("job changed to %+v by a refused update", stored.Job)
+				}
+				return
+			}
+			var resp jobs.JobResponse
+			decode(t, rec, &resp)
+			if resp.Status != "updated" || resp.Name != "ping" {
+				t.Errorf("PUT %s returned %+v, want status updated", tt.path, resp)
+			}
+			tt.check(t, resp.Job)
+			tt.check(t, stored.Job)
+		})
+	}
+}
+
+func TestJobRunHandler(t *testing.T) {
+	router, jm := newTestRouter(t)
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	registerJob(t, jm, "ping", server.URL)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{name: "run", method: http.MethodPost, path: "/jobs/ping/run", status: http.StatusAccepted},
+		{name: "unknown job", method: http.MethodPost, path: "/jobs/missing/run", status: http.StatusNotFound},
+		{name: "GET", method: http.MethodGet, path: "/jobs/ping/run", status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, router, tt.method, tt.path, "")
+			if rec.Code != tt.status {
+				t.Fatalf("%s %s returned %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+			}
+			if tt.status != http.StatusAccepted {
+				return
+			}
+			var resp jobs.JobResponse
+			decode(t, rec, &resp)
+			if resp.Status != "triggered" || resp.Name != "ping" {
+				t.Errorf("%s %s returned %+v, want status triggered", tt.method, tt.path, resp)
+			}
+		})
+	}
+
+	waitForManualRun(t, jm, "ping")
+
+	// Once draining, runs are refused and the client is told to retry
+	jm.Drain()
+	rec := serve(t, router, http.MethodPost, "/jobs/ping/run", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("POST /jobs/ping/run while draining returned %d with Retry-After %q, want 503 with a delay",
+			rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestJobListAndGetHandlers(t *testing.T) {
+	router, jm := newTestRouter(t)
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	registerJob(t, jm, "ping", server.URL)
+	registerJob(t, jm, "idle", server.URL)
+	jm.StartScheduling()
+	if err := jm.Pause("idle"); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := jm.Trigger("ping"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	waitForManualRun(t, jm, "ping")
+
+	rec := serve(t, router, http.MethodGet, "/jobs/list", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /jobs/list returned %d, want 200", rec.Code)
+	}
+	var list jobs.JobListResponse
+	decode(t, rec, &list)
+	if len(list.Jobs) != 2 || list.Jobs[0].Name != "idle" || list.Jobs[1].Name != "ping" {
+		t.Fatalf("GET /jobs/list returned %+v, want idle and ping sorted by name", list.Jobs)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		status     int
+		paused     bool
+		nextRun    bool
+		lastStatus jobs.ExecutionStatus
+	}{
+		{name: "job with a run", path: "/jobs/ping", status: http.StatusOK, nextRun: true, lastStatus: jobs.ExecutionSucceeded},
+		{name: "paused job", path: "/jobs/idle", status: http.StatusOK, paused: true},
+		{name: "unknown job", path: "/jobs/missing", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, router, http.MethodGet, tt.path, "")
+			if rec.Code != tt.status {
+				t.Fatalf("GET %s returned %d, want %d", tt.path, rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp jobs.JobDetailResponse
+			decode(t, rec, &resp)
+			job := resp.Job
+			if job.Endpoint != server.URL || job.Cron != "0 0 1 1 *" || job.Format != jobs.ScheduleStandard {
+				t.Errorf("GET %s returned definition %+v, want the registered one", tt.path, job.Job)
+			}
+			if job.Paused != tt.paused || (job.NextRun != nil) != tt.nextRun || job.LastStatus != tt.lastStatus {
+				t.Errorf("GET %s returned paused %v, next run %v and last status %q, want %v, %v and %q",
+					tt.path, job.Paused, job.NextRun, job.LastStatus, tt.paused, tt.nextRun, tt.lastStatus)
+			}
+
+			// The list holds the same state
+			for _, listed := range list.Jobs {
+				if listed.Name == job.Name && (listed.Paused != job.Paused || listed.LastStatus != job.LastStatus) {
+					t.Errorf("GET /jobs/list returned %+v for %s, want %+v", listed, job.Name, job)
+				}
+			}
+		})
+	}
+
+	if rec := serve(t, router, http.MethodDelete, "/jobs/ping", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /jobs/ping returned %d, want 405", rec.Code)
+	}
+}
+
+func TestTimezones(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		timezone string
+	}{
+		{name: "timezone", body: `{"cron":"0 9 * * *","timezone":"America/New_York"}`, status: http.StatusOK, timezone: "America/New_York"},
+		{name: "cron prefix", body: `{"cron":"CRON_TZ=Asia/Tokyo 0 9 * * *"}`, status: http.StatusOK, timezone: "Asia/Tokyo"},
+		{name: "matching prefix and timezone", body: `{"cron":"CRON_TZ=Asia/Tokyo 0 9 * * *","timezone":"Asia/Tokyo"}`, status: http.StatusOK, timezone: "Asia/Tokyo"},
+		{name: "conflicting prefix", body: `{"cron":"CRON_TZ=Asia/Tokyo 0 9 * * *","timezone":"Europe/Paris"}`, status: http.StatusBadRequest},
+		{name: "unknown timezone", body: `{"cron":"0 9 * * *","timezone":"Mars/Olympus"}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, jm := newTestRouter(t)
+			jm.StartScheduling()
+
+			rec := serve(t, router, http.MethodPost, "/jobs/validate", tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("POST /jobs/validate %s returned %d, want %d: %s", tt.body, rec.Code, tt.status, rec.Body.String())
+			}
+
+			// Registering the same schedule gives the same answer
+			var schedule map[string]any
+			json.Unmarshal([]byte(tt.body), &schedule)
+			schedule["name"] = "report"
+			schedule["endpoint"] = "http://localhost:3000/report"
+			job, _ := json.Marshal(schedule)
+			registered := serve(t, router, http.MethodPost, "/jobs/register", string(job))
+			wantRegister := http.StatusCreated
+			if tt.status != http.StatusOK {
+				wantRegister = tt.status
+			}
+			if registered.Code != wantRegister {
+				t.Fatalf("POST /jobs/register %s returned %d, want %d", job, registered.Code, wantRegister)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp jobs.ScheduleResponse
+			decode(t, rec, &resp)
+			if resp.Schedule.Timezone != tt.timezone || len(resp.Schedule.NextRunsLocal) == 0 {
+				t.Fatalf("schedule preview is %+v, want runs in %s", resp.Schedule, tt.timezone)
+			}
+			loc, _ := time.LoadLocation(tt.timezone)
+			for i, local := range resp.Schedule.NextRunsLocal {
+				if local.In(loc).Hour() != 9 || !local.Equal(resp.Schedule.NextRuns[i]) {
+					t.Errorf("run %d is at %s (%s UTC), want 09:00 in %s", i, local, resp.Schedule.NextRuns[i], tt.timezone)
+				}
+			}
+
+			stored, err := jm.Get("report")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if stored.Timezone != tt.timezone || stored.NextRunLocal == nil || stored.NextRunLocal.In(loc).Hour() != 9 {
+				t.Errorf("registered job has timezone %q and next local run %v, want 09:00 in %s", stored.Timezone, stored.NextRunLocal, tt.timezone)
+			}
+		})
+	}
+}
+
+func TestJobValidateHandler(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		format jobs.ScheduleFormat
+		runs   int
+		// every is the time between consecutive runs, if it is fixed
+		every time.Duration
+	}{
+		{name: "standard", body: `{"cron":"*/5 * * * *","timezone":"UTC"}`, status: http.StatusOK, format: jobs.ScheduleStandard, runs: 5, every: 5 * time.Minute},
+		{name: "seconds field", body: `{"cron":"*/10 * * * * *"}`, status: http.StatusOK, format: jobs.ScheduleSeconds, runs: 5, every: 10 * time.Second},
+		{name: "descriptor", body: `{"cron":"@hourly","timezone":"UTC","count":3}`, status: http.StatusOK, format: jobs.ScheduleDescriptor, runs: 3, every: time.Hour},
+		{name: "interval", body: `{"cron":"@every 90s","count":2}`, status: http.StatusOK, format: jobs.ScheduleDescriptor, runs: 2, every: 90 * time.Second},
+		{name: "count capped", body: `{"cron":"@daily","timezone":"UTC","count":1000}`, status: http.StatusOK, format: jobs.ScheduleDescriptor, runs: 100, every: 24 * time.Hour},
+		{name: "too few fields", body: `{"cron":"* * *"}`, status: http.StatusBadRequest},
+		{name: "unknown descriptor", body: `{"cron":"@fortnightly"}`, status: http.StatusBadRequest},
+		{name: "invalid body", body: `{"cron":`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, router, http.MethodPost, "/jobs/validate", tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("POST /jobs/validate %s returned %d, want %d: %s", tt.body, rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp jobs.ScheduleResponse
+			decode(t, rec, &resp)
+			runs := resp.Schedule.NextRuns
+			if resp.Status != "valid" || resp.Schedule.Format != tt.format || len(runs) != tt.runs {
+				t.Fatalf("schedule preview is %s with format %s and %d run(s), want valid, %s and %d",
+					resp.Status, resp.Schedule.Format, len(runs), tt.format, tt.runs)
+			}
+			for i := 1; i < len(runs); i++ {
+				if gap := runs[i].Sub(runs[i-1]); gap != tt.every {
+					t.Errorf("runs %d and %d are %s apart, want %s", i-1, i, gap, tt.every)
+				}
+			}
+		})
+	}
+
+	if rec := serve(t, router, http.MethodGet, "/jobs/validate", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /jobs/validate returned %d, want 405", rec.Code)
+	}
+}
+
+func TestRegisterSecondsCron(t *testing.T) {
+	router, jm := newTestRouter(t)
+	jm.StartScheduling()
+
+	rec := serve(t, router, http.MethodPost, "/jobs/register", `{"name":"ping","cron":"*/10 * * * * *","endpoint":"http://localhost:3000/ping"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /jobs/register returned %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+	job, err := jm.Get("ping")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if job.Format != jobs.ScheduleSeconds || job.NextRun == nil || job.NextRun.Second()%10 != 0 {
+		t.Errorf("registered job has format %s and next run %v, want a seconds schedule on a multiple of 10s", job.Format, job.NextRun)
+	}
+	if until := time.Until(*job.NextRun); until > 10*time.Second {
+		t.Errorf("next run is in %s, want within 10s", until)
+	}
+}
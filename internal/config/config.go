@@ -0,0 +1,290 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"schedulerservice/internal/logging"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// FileEnv is the environment variable naming the configuration file, also set with -config
+const FileEnv = "CONFIG_FILE"
+
+// Config holds the settings of the service. Each setting is resolved, from lowest to
+// highest precedence, from its default, the configuration file, the environment and
+// the command line flags
+type Config struct {
+	Port        int      `json:"port" yaml:"port"`
+	LogLevel    string   `json:"log_level" yaml:"log_level"`
+	RegistryURL string   `json:"registry_url" yaml:"registry_url"`
+	Database    Database `json:"database" yaml:"database"`
+	Kafka       Kafka    `json:"kafka" yaml:"kafka"`
+	Leader      Leader   `json:"leader" yaml:"leader"`
+	Timeouts    Timeouts `json:"timeouts" yaml:"timeouts"`
+}
+
+// Database selects where jobs, executions and metrics are kept
+type Database struct {
+	// Driver is sqlite3 or postgres
+	Driver string `json:"driver" yaml:"driver"`
+	// Path is the SQLite database file
+	Path string `json:"path" yaml:"path"`
+	// URL is the Postgres connection string
+	URL string `json:"url" yaml:"url"`
+}
+
+// Kafka configures the brokers used by the Kafka targets and events, and the consumer of
+// job messages. Kafka is disabled without brokers, and the consumer without a topic
+type Kafka struct {
+	Brokers  []string `json:"brokers" yaml:"brokers"`
+	Topic    string   `json:"topic" yaml:"topic"`
+	GroupID  string   `json:"group_id" yaml:"group_id"`
+	DLQTopic string   `json:"dlq_topic" yaml:"dlq_topic"`
+	// EventsTopic receives the job and execution events, none are published if empty
+	EventsTopic string `json:"events_topic" yaml:"events_topic"`
+	// ReplyTopic receives the result of every message, no results are published if empty
+	ReplyTopic string `json:"reply_topic" yaml:"reply_topic"`
+	// RetryDelays are the delays of the retry tiers, each consumed from its own topic
+	RetryDelays []Duration `json:"retry_delays" yaml:"retry_delays"`
+}
+
+// Enabled reports whether Kafka brokers are configured, which the Kafka targets and the
+// events need
+func (k Kafka) Enabled() bool {
+	return len(k.Brokers) > 0
+}
+
+// ConsumerEnabled reports whether the consumer of job messages should run
+func (k Kafka) ConsumerEnabled() bool {
+	return k.Enabled() && k.Topic != ""
+}
+
+// Leader configures the election of the replica running the scheduled jobs
+type Leader struct {
+	Enabled    bool     `json:"enabled" yaml:"enabled"`
+	InstanceID string   `json:"instance_id" yaml:"instance_id"`
+	LeaseTTL   Duration `json:"lease_ttl" yaml:"lease_ttl"`
+	// SyncInterval is how often the jobs are reloaded from the database, to pick up the
+	// changes made through the other replicas
+	SyncInterval Duration `json:"sync_interval" yaml:"sync_interval"`
+}
+
+// Timeouts bounds the HTTP server, the shutdown and the calls to the service registry
+type Timeouts struct {
+	HTTPRead  Duration `json:"http_read" yaml:"http_read"`
+	HTTPWrite Duration `json:"http_write" yaml:"http_write"`
+	HTTPIdle  Duration `json:"http_idle" yaml:"http_idle"`
+	Shutdown  Duration `json:"shutdown" yaml:"shutdown"`
+	Registry  Duration `json:"registry" yaml:"registry"`
+}
+
+// Addr returns the address the HTTP server listens on
+func (c *Config) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() *Config {
+	host, _ := os.Hostname()
+	return &Config{
+		Port:     8080,
+		LogLevel: string(logging.Info),
+		Database: Database{
+			Driver: "sqlite3",
+			Path:   "jobs.db",
+		},
+		Kafka: Kafka{
+			GroupID:     "schedulerservice",
+			RetryDelays: []Duration{Duration(10 * time.Second), Duration(time.Minute), Duration(10 * time.Minute)},
+		},
+		Leader: Leader{
+			InstanceID:   host + "-" + strconv.Itoa(os.Getpid()),
+			LeaseTTL:     Duration(15 * time.Second),
+			SyncInterval: Duration(10 * time.Second),
+		},
+		Timeouts: Timeouts{
+			HTTPRead:  Duration(15 * time.Second),
+			HTTPWrite: Duration(30 * time.Second),
+			HTTPIdle:  Duration(60 * time.Second),
+			Shutdown:  Duration(30 * time.Second),
+			Registry:  Duration(10 * time.Second),
+		},
+	}
+}
+
+// Load resolves the configuration from the defaults, the file named by -config or
+// CONFIG_FILE, the environment and the flags in args, and validates it. It returns the
+// arguments left after the flags
+func Load(name string, args []string) (*Config, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", os.Getenv(FileEnv), "path of a YAML or JSON configuration file (env "+FileEnv+")")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		record := func(value string) error {
+			flagValues[s.flag] = value
+			return nil
+		}
+		if s.boolean {
+			fs.BoolFunc(s.flag, usage, record)
+		} else {
+			fs.Func(s.flag, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.loadFile(*file); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var problems []string
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && (value != "" || s.allowEmpty) {
+			if err := s.set(cfg, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := flagValues[s.flag]; ok {
+			if err := s.set(cfg, value); err != nil {
+				problems = append(problems, fmt.Sprintf("-%s: %v", s.flag, err))
+			}
+		}
+	}
+
+	if err := problemsError(append(problems, cfg.problems()...)); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile overrides the settings present in a configuration file, read as JSON when its
+// extension is .json and as YAML otherwise. Unknown keys are rejected
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		err = yaml.UnmarshalStrict(data, c)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	return problemsError(c.problems())
+}
+
+// problems describes each invalid setting
+func (c *Config) problems() []string {
+	var problems []string
+	invalid := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		invalid("port %d must be between 1 and 65535", c.Port)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		invalid("log_level: %v", err)
+	}
+	if c.RegistryURL != "" {
+		if u, err := url.Parse(c.RegistryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("registry_url %q must be an http or https URL", c.RegistryURL)
+		}
+	}
+
+	switch c.Database.Driver {
+	case "sqlite", "sqlite3":
+		if c.Database.Path == "" {
+			invalid("database.path must be set to use sqlite")
+		}
+	case "postgres", "postgresql":
+		if c.Database.URL == "" {
+			invalid("database.url must be set to use postgres")
+		}
+	default:
+		invalid("database.driver %q must be sqlite3 or postgres", c.Database.Driver)
+	}
+
+	if c.Kafka.ConsumerEnabled() {
+		if c.Kafka.GroupID == "" {
+			invalid("kafka.group_id must be set along with kafka.topic")
+		}
+		if c.Kafka.ReplyTopic != "" && c.Kafka.ReplyTopic == c.Kafka.Topic {
+			invalid("kafka.reply_topic must differ from kafka.topic")
+		}
+		if c.Kafka.EventsTopic != "" && c.Kafka.EventsTopic == c.Kafka.Topic {
+			invalid("kafka.events_topic must differ from kafka.topic")
+		}
+	}
+	for _, delay := range c.Kafka.RetryDelays {
+		if delay <= 0 {
+			invalid("kafka.retry_delays %s must be greater than 0", delay)
+		}
+	}
+
+	if c.Leader.Enabled && c.Leader.LeaseTTL.Std() < time.Second {
+		invalid("leader.lease_ttl %s must be at least 1s", c.Leader.LeaseTTL)
+	}
+	if c.Leader.SyncInterval <= 0 {
+		invalid("leader.sync_interval %s must be greater than 0", c.Leader.SyncInterval)
+	}
+	if c.Leader.InstanceID == "" {
+		invalid("leader.instance_id must not be empty")
+	}
+
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"timeouts.http_read", c.Timeouts.HTTPRead},
+		{"timeouts.http_write", c.Timeouts.HTTPWrite},
+		{"timeouts.http_idle", c.Timeouts.HTTPIdle},
+		{"timeouts.shutdown", c.Timeouts.Shutdown},
+		{"timeouts.registry", c.Timeouts.Registry},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			invalid("%s %s must not be negative", timeout.name, timeout.value)
+		}
+	}
+	if c.Timeouts.Shutdown == 0 {
+		invalid("timeouts.shutdown must be greater than 0")
+	}
+
+	return problems
+}
+
+// problemsError lists problems in a single error, or returns nil if there are none
+func problemsError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+}
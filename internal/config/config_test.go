@@ -0,0 +1,238 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every variable Load reads, restoring them when the test ends
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range append([]string{FileEnv}, envNames()...) {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+}
+
+func envNames() []string {
+	names := make([]string, len(settings))
+	for i, s := range settings {
+		names[i] = s.env
+	}
+	return names
+}
+
+// writeFile writes a configuration file in the test's temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := "port: 9000\nlog_level: warn\ndatabase:\n  path: file.db\nkafka:\n  brokers: [file:9092]\n  topic: file-topic\n"
+	jsonFile := `{"port": 9000, "log_level": "warn", "database": {"path": "file.db"}, "kafka": {"brokers": ["file:9092"], "topic": "file-topic"}}`
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		env      map[string]string
+		args     []string
+		port     int
+		logLevel string
+		dbPath   string
+		topic    string
+	}{
+		{name: "defaults", port: 8080, logLevel: "info", dbPath: "jobs.db"},
+		{name: "yaml file over defaults", file: "config.yaml", content: yamlFile, port: 9000, logLevel: "warn", dbPath: "file.db", topic: "file-topic"},
+		{name: "json file over defaults", file: "config.json", content: jsonFile, port: 9000, logLevel: "warn", dbPath: "file.db", topic: "file-topic"},
+		{
+			name: "env over file", file: "config.yaml", content: yamlFile,
+			env:  map[string]string{"PORT": "9100", "KAFKA_TOPIC": "env-topic"},
+			port: 9100, logLevel: "warn", dbPath: "file.db", topic: "env-topic",
+		},
+		{
+			name: "flags over env", file: "config.yaml", content: yamlFile,
+			env:  map[string]string{"PORT": "9100", "DB_PATH": "env.db"},
+			args: []string{"-port", "9200", "-log-level", "debug"},
+			port: 9200, logLevel: "debug", dbPath: "env.db", topic: "file-topic",
+		},
+		{name: "empty env is ignored", env: map[string]string{"PORT": "", "DB_PATH": ""}, port: 8080, logLevel: "info", dbPath: "jobs.db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.content)}, args...)
+			}
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+
+			cfg, _, err := Load("test", args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Port != tt.port || cfg.LogLevel != tt.logLevel || cfg.Database.Path != tt.dbPath || cfg.Kafka.Topic != tt.topic {
+				t.Errorf("Load resolved port %d, log level %s, database path %s and topic %q, want %d, %s, %s and %q",
+					cfg.Port, cfg.LogLevel, cfg.Database.Path, cfg.Kafka.Topic, tt.port, tt.logLevel, tt.dbPath, tt.topic)
+			}
+		})
+	}
+}
+
+func TestLoadFileFromEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(FileEnv, writeFile(t, "config.yaml", "port: 9000\n"))
+
+	cfg, rest, err := Load("test", []string{"-port", "9001", "run"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Port != 9001 {
+		t.Errorf("port is %d, want the flag's 9001", cfg.Port)
+	}
+	if !reflect.DeepEqual(rest, []string{"run"}) {
+		t.Errorf("Load left arguments %v, want [run]", rest)
+	}
+
+	// -config takes precedence over CONFIG_FILE
+	cfg, _, err = Load("test", []string{"-config", writeFile(t, "other.yaml", "port: 9002\n")})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Port != 9002 {
+		t.Errorf("port is %d, want 9002 from the -config file", cfg.Port)
+	}
+}
+
+func TestLoadKafka(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		delays   []Duration
+		enabled  bool
+		consumer bool
+	}{
+		{
+			name:   "disabled without brokers",
+			delays: []Duration{Duration(10 * time.Second), Duration(time.Minute), Duration(10 * time.Minute)},
+		},
+		{
+			name:    "brokers without a topic",
+			env:     map[string]string{"KAFKA_BROKERS": "a:9092, b:9092,"},
+			delays:  []Duration{Duration(10 * time.Second), Duration(time.Minute), Duration(10 * time.Minute)},
+			enabled: true,
+		},
+		{
+			name:    "custom retry delays",
+			env:     map[string]string{"KAFKA_BROKERS": "a:9092", "KAFKA_TOPIC": "jobs", "KAFKA_RETRY_DELAYS": "5s,30s"},
+			delays:  []Duration{Duration(5 * time.Second), Duration(30 * time.Second)},
+			enabled: true, consumer: true,
+		},
+		{
+			name:    "empty retry delays turn retries off",
+			env:     map[string]string{"KAFKA_BROKERS": "a:9092", "KAFKA_TOPIC": "jobs", "KAFKA_RETRY_DELAYS": ""},
+			enabled: true, consumer: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+
+			cfg, _, err := Load("test", nil)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if len(cfg.Kafka.RetryDelays) != len(tt.delays) || (len(tt.delays) > 0 && !reflect.DeepEqual(cfg.Kafka.RetryDelays, tt.delays)) {
+				t.Errorf("retry delays are %v, want %v", cfg.Kafka.RetryDelays, tt.delays)
+			}
+			if cfg.Kafka.Enabled() != tt.enabled || cfg.Kafka.ConsumerEnabled() != tt.consumer {
+				t.Errorf("Kafka enabled: %v, consumer enabled: %v, want %v and %v",
+					cfg.Kafka.Enabled(), cfg.Kafka.ConsumerEnabled(), tt.enabled, tt.consumer)
+			}
+		})
+	}
+
+	clearEnv(t)
+	if cfg, _, _ := Load("test", []string{"-kafka-brokers", "a:9092"}); cfg == nil || !reflect.DeepEqual(cfg.Kafka.Brokers, []string{"a:9092"}) {
+		t.Errorf("-kafka-brokers did not set the brokers")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want []string
+	}{
+		{name: "unknown file key", file: "port: 9000\nprot: 9001\n", want: []string{"invalid configuration file", "prot"}},
+		{name: "invalid file duration", file: "leader:\n  lease_ttl: soon\n", want: []string{`invalid duration "soon"`}},
+		{name: "invalid env number", env: map[string]string{"PORT": "http"}, want: []string{`PORT: invalid number "http"`}},
+		{name: "invalid flag duration", args: []string{"-shutdown-timeout", "1x"}, want: []string{`-shutdown-timeout: invalid duration "1x"`}},
+		{name: "unknown flag", args: []string{"-verbose"}, want: []string{"-verbose"}},
+		{
+			name: "every problem at once",
+			env:  map[string]string{"PORT": "70000", "LOG_LEVEL": "loud", "DB_DRIVER": "mysql"},
+			want: []string{"port 70000", "log_level", `database.driver "mysql"`},
+		},
+		{name: "postgres without a URL", env: map[string]string{"DB_DRIVER": "postgres"}, want: []string{"database.url must be set"}},
+		{name: "registry URL without a scheme", env: map[string]string{"SERVICE_REGISTRY_URL": "registry:8500"}, want: []string{"registry_url"}},
+		{name: "zero sync interval", env: map[string]string{"LEADER_SYNC_INTERVAL": "0s"}, want: []string{"leader.sync_interval 0s must be greater than 0"}},
+		{
+			name: "short lease with election",
+			env:  map[string]string{"LEADER_ELECTION": "true", "LEADER_LEASE_TTL": "500ms"},
+			want: []string{"leader.lease_ttl 500ms must be at least 1s"},
+		},
+		{name: "zero retry delay", env: map[string]string{"KAFKA_RETRY_DELAYS": "10s,0s"}, want: []string{"kafka.retry_delays 0s must be greater than 0"}},
+		{
+			name: "reply topic is the consumed topic",
+			env:  map[string]string{"KAFKA_BROKERS": "a:9092", "KAFKA_TOPIC": "jobs", "KAFKA_REPLY_TOPIC": "jobs"},
+			want: []string{"kafka.reply_topic must differ from kafka.topic"},
+		},
+		{name: "negative timeout", args: []string{"-http-read-timeout", "-1s"}, want: []string{"timeouts.http_read -1s must not be negative"}},
+		{name: "zero shutdown timeout", env: map[string]string{"SHUTDOWN_TIMEOUT": "0s"}, want: []string{"timeouts.shutdown must be greater than 0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, "config.yaml", tt.file)}, args...)
+			}
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+
+			_, _, err := Load("test", args)
+			if err == nil {
+				t.Fatalf("Load succeeded, want an error mentioning %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("default configuration is invalid: %v", err)
+	}
+}
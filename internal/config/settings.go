@@ -0,0 +1,186 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting is a configuration value that can be overridden from the environment and the
+// command line
+type setting struct {
+	env     string
+	flag    string
+	usage   string
+	boolean bool
+	// allowEmpty applies an empty environment variable instead of ignoring it, for the
+	// settings that an empty value turns off
+	allowEmpty bool
+	set        func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{env: "PORT", flag: "port", usage: "port of the HTTP server", set: func(c *Config, v string) error {
+		return setInt(&c.Port, v)
+	}},
+	{env: "LOG_LEVEL", flag: "log-level", usage: "lowest level logged: debug, info, warn or error", set: func(c *Config, v string) error {
+		c.LogLevel = v
+		return nil
+	}},
+	{env: "SERVICE_REGISTRY_URL", flag: "registry-url", usage: "base URL of the service registry, registration is skipped if empty", set: func(c *Config, v string) error {
+		c.RegistryURL = strings.TrimSuffix(v, "/")
+		return nil
+	}},
+	{env: "DB_DRIVER", flag: "db-driver", usage: "database driver: sqlite3 or postgres", set: func(c *Config, v string) error {
+		c.Database.Driver = v
+		return nil
+	}},
+	{env: "DB_PATH", flag: "db-path", usage: "path of the SQLite database file", set: func(c *Config, v string) error {
+		c.Database.Path = v
+		return nil
+	}},
+	{env: "DATABASE_URL", flag: "database-url", usage: "Postgres connection string", set: func(c *Config, v string) error {
+		c.Database.URL = v
+		return nil
+	}},
+	{env: "KAFKA_BROKERS", flag: "kafka-brokers", usage: "comma-separated Kafka brokers, the consumer is disabled if empty", set: func(c *Config, v string) error {
+		c.Kafka.Brokers = splitList(v)
+		return nil
+	}},
+	{env: "KAFKA_TOPIC", flag: "kafka-topic", usage: "topic of the job messages", set: func(c *Config, v string) error {
+		c.Kafka.Topic = v
+		return nil
+	}},
+	{env: "KAFKA_GROUP_ID", flag: "kafka-group-id", usage: "consumer group of the service", set: func(c *Config, v string) error {
+		c.Kafka.GroupID = v
+		return nil
+	}},
+	{env: "KAFKA_DLQ_TOPIC", flag: "kafka-dlq-topic", usage: "topic of the messages that could not be processed", set: func(c *Config, v string) error {
+		c.Kafka.DLQTopic = v
+		return nil
+	}},
+	{env: "KAFKA_EVENTS_TOPIC", flag: "kafka-events-topic", usage: "topic of the job and execution events, none are published if empty", set: func(c *Config, v string) error {
+		c.Kafka.EventsTopic = v
+		return nil
+	}},
+	{env: "KAFKA_REPLY_TOPIC", flag: "kafka-reply-topic", usage: "topic of the results of the messages, none are published if empty", set: func(c *Config, v string) error {
+		c.Kafka.ReplyTopic = v
+		return nil
+	}},
+	{env: "KAFKA_RETRY_DELAYS", flag: "kafka-retry-delays", usage: "comma-separated delays of the retry topics, failed messages go to the DLQ if empty", allowEmpty: true, set: func(c *Config, v string) error {
+		return setDurations(&c.Kafka.RetryDelays, v)
+	}},
+	{env: "LEADER_ELECTION", flag: "leader-election", usage: "elect a leader among the replicas", boolean: true, set: func(c *Config, v string) error {
+		return setBool(&c.Leader.Enabled, v)
+	}},
+	{env: "INSTANCE_ID", flag: "instance-id", usage: "name of this replica", set: func(c *Config, v string) error {
+		c.Leader.InstanceID = v
+		return nil
+	}},
+	{env: "LEADER_LEASE_TTL", flag: "lease-ttl", usage: "how long the leader's lease lasts without renewal", set: func(c *Config, v string) error {
+		return c.Leader.LeaseTTL.Set(v)
+	}},
+	{env: "LEADER_SYNC_INTERVAL", flag: "sync-interval", usage: "how often the jobs changed through other replicas are reloaded from the database", set: func(c *Config, v string) error {
+		return c.Leader.SyncInterval.Set(v)
+	}},
+	{env: "HTTP_READ_TIMEOUT", flag: "http-read-timeout", usage: "longest time to read a request, 0 for none", set: func(c *Config, v string) error {
+		return c.Timeouts.HTTPRead.Set(v)
+	}},
+	{env: "HTTP_WRITE_TIMEOUT", flag: "http-write-timeout", usage: "longest time to write a response, 0 for none", set: func(c *Config, v string) error {
+		return c.Timeouts.HTTPWrite.Set(v)
+	}},
+	{env: "HTTP_IDLE_TIMEOUT", flag: "http-idle-timeout", usage: "longest time to keep an idle connection open, 0 for none", set: func(c *Config, v string) error {
+		return c.Timeouts.HTTPIdle.Set(v)
+	}},
+	{env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "longest time to wait for running work on shutdown", set: func(c *Config, v string) error {
+		return c.Timeouts.Shutdown.Set(v)
+	}},
+	{env: "REGISTRY_TIMEOUT", flag: "registry-timeout", usage: "timeout of the calls to the service registry, 0 for none", set: func(c *Config, v string) error {
+		return c.Timeouts.Registry.Set(v)
+	}},
+}
+
+func setInt(dest *int, value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*dest = parsed
+	return nil
+}
+
+func setBool(dest *bool, value string) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", value)
+	}
+	*dest = parsed
+	return nil
+}
+
+func setDurations(dest *[]Duration, value string) error {
+	items := splitList(value)
+	durations := make([]Duration, len(items))
+	for i, item := range items {
+		if err := durations[i].Set(item); err != nil {
+			return err
+		}
+	}
+	*dest = durations
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Duration is a time.Duration written as a string like "1m30s" in configuration files
+type Duration time.Duration
+
+// Std returns the duration as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses a duration such as "30s"
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid duration %s: must be a string like \"30s\"", string(data))
+	}
+	return d.Set(value)
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(any) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	return d.Set(value)
+}
@@ -2,149 +2,190 @@ package db
 
 import (
 	"database/sql"
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
+	"schedulerservice/internal/config"
+	"schedulerservice/internal/jobs"
 	"schedulerservice/internal/metrics"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var (
-	db  *sql.DB
-	err error
-)
+// seedFile declares the rows the service expects to find in its tables
+//
+//go:embed db-seed.json
+var seedFile []byte
 
-const dbPath = "jobs.db"
-const dbTablesPath = "internal/db/db-tables.json"
+// globalMetricJob is the job_name of the service-wide rows of the metrics table
+const globalMetricJob = "global"
 
-// InitDBConnection initializes the database connection
-func InitDBConnection() error {
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		log.Println("Database file does not exist, creating...")
-		file, err := os.Create(dbPath)
-		if err != nil {
-			return fmt.Errorf("failed to create database file: %w", err)
+// SQLStore is the jobs.Store kept in a SQLite or Postgres database
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+var _ jobs.Store = (*SQLStore)(nil)
+
+// OpenConfig opens the database selected by cfg.Driver: the SQLite file at cfg.Path,
+// or the Postgres database at cfg.URL
+func OpenConfig(cfg config.Database) (*SQLStore, error) {
+	switch cfg.Driver {
+	case "sqlite", string(SQLite):
+		return Open(SQLite, cfg.Path)
+	case string(Postgres), "postgresql":
+		return Open(Postgres, cfg.URL)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+// Open connects to the database at dsn, which is a file path for SQLite. The schema
+// is brought up to date separately with Migrate
+func Open(dialect Dialect, dsn string) (*SQLStore, error) {
+	if dialect == SQLite {
+		if _, err := os.Stat(dsn); os.IsNotExist(err) {
+			log.Println("Database file does not exist, creating...")
+			file, err := os.Create(dsn)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create database file: %w", err)
+			}
+			file.Close()
+			log.Println("Database file created successfully")
 		}
-		file.Close()
-		log.Println("Database file created successfully")
+		// Wait for locks held by other processes sharing the file instead of failing right away,
+		// and take the write lock when a transaction begins so concurrent ones cannot deadlock
+		dsn = "file:" + dsn + "?_busy_timeout=5000&_txlock=immediate"
 	}
 
-	db, err = sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(string(dialect), dsn)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	CreateDBTables()
-	ScheduleDBPing()
-	return nil
+	s := &SQLStore{db: db, dialect: dialect}
+	s.ScheduleDBPing()
+	return s, nil
 }
 
-// GetDB returns the database connection
-func GetDB() *sql.DB {
-	if db == nil {
-		InitDBConnection()
-	}
-	return db
+// Dialect returns the SQL flavour of the database
+func (s *SQLStore) Dialect() Dialect {
+	return s.dialect
 }
 
-// CloseDB closes the database connection
-func CloseDB() error {
-	if db != nil {
-		return db.Close()
-	}
-	return nil
+// DB returns the database connection, for components keeping their own tables in it
+func (s *SQLStore) DB() *sql.DB {
+	return s.db
+}
+
+// Close closes the database connection
+func (s *SQLStore) Close() error {
+	return s.db.Close()
 }
 
 // PingDB pings the database to check its availability
-func PingDB() error {
-	if db != nil {
-		return db.Ping()
-	}
-	return fmt.Errorf("database not initialized")
+func (s *SQLStore) PingDB() error {
+	return s.db.Ping()
 }
 
 // ScheduleDBPing periodically checks the database connection
-func ScheduleDBPing() error {
+func (s *SQLStore) ScheduleDBPing() {
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
 		defer ticker.Stop()
 		for range ticker.C {
-			if pingErr := PingDB(); pingErr != nil {
+			if pingErr := s.PingDB(); pingErr != nil {
 				log.Printf("PingDB error: %v\n", pingErr)
 			} else {
 				log.Println("PingDB successful")
 			}
 		}
 	}()
-	return nil
 }
 
-// CreateDBTables creates the necessary tables in the database
-func CreateDBTables() error {
-	data, err := os.ReadFile(dbTablesPath)
+// LoadMetrics returns the stored metric values
+func (s *SQLStore) LoadMetrics() ([]jobs.Metric, error) {
+	rows, err := s.query("SELECT metric_name, job_name, metric_value FROM metrics")
 	if err != nil {
-		return fmt.Errorf("failed to read db-tables.json: %w", err)
-	}
-
-	var tables struct {
-		Tables []struct {
-			Name string `json:"name"`
-			SQL  string `json:"sql"`
-		} `json:"tables"`
-	}
-	if err := json.Unmarshal(data, &tables); err != nil {
-		return fmt.Errorf("failed to parse db-tables.json: %w", err)
+		return nil, fmt.Errorf("failed to load metrics: %w", err)
 	}
+	defer rows.Close()
 
-	for _, table := range tables.Tables {
-		if _, err := db.Exec(table.SQL); err != nil {
-			return fmt.Errorf("failed to create table %q: %w", table.Name, err)
+	var stored []jobs.Metric
+	for rows.Next() {
+		var metric jobs.Metric
+		if err := rows.Scan(&metric.Name, &metric.JobName, &metric.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan metric: %w", err)
 		}
-		log.Printf("Table %q created successfully\n", table.Name)
+		if metric.JobName == globalMetricJob {
+			metric.JobName = ""
+		}
+		stored = append(stored, metric)
 	}
-
-	return nil
+	return stored, rows.Err()
 }
 
-// UpdateGlobalMetric updates the value of a global metric in the database
-func UpdateGlobalMetric(name metrics.MetricName, value float64) error {
-	if db == nil {
-		log.Printf("[WARN] Database connection not set for metrics")
-		return fmt.Errorf("database connection not set")
-	}
-
-	_, err := db.Exec(`
+// AddGlobalMetric updates the value of a global metric in the database. Its row is
+// created by SeedDB
+func (s *SQLStore) AddGlobalMetric(name metrics.MetricName, value float64) error {
+	res, err := s.exec(`
         UPDATE metrics SET
         metric_value = metric_value + ?,
         recorded_at = CURRENT_TIMESTAMP
-        WHERE metric_name = ?
-    `, value, string(name))
-	return err
-}
-
-// UpdateMetric updates the value of a metric associated with a specific job in the database
-func UpdateMetric(name metrics.MetricName, value float64, jobName string) error {
-	if db == nil {
-		log.Printf("[WARN] Database connection not set for metrics")
-		return fmt.Errorf("database connection not set")
+        WHERE metric_name = ? AND job_name = ?
+    `, value, string(name), globalMetricJob)
+	if err != nil {
+		return err
 	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("global metric %s has no row in the database", name)
+	}
+	return nil
+}
 
-	_, err := db.Exec(`
+// AddJobMetric updates the value of a metric associated with a specific job in the database
+func (s *SQLStore) AddJobMetric(name metrics.MetricName, jobName string, value float64) error {
+	_, err := s.exec(`
         INSERT INTO metrics (metric_name, metric_value, job_name, recorded_at)
 				VALUES (?, ?, ?, CURRENT_TIMESTAMP)
 				ON CONFLICT(metric_name, job_name) DO UPDATE SET
-				metric_value = metric_value + ?,
+				metric_value = metrics.metric_value + ?,
 				recorded_at = CURRENT_TIMESTAMP
-    `, value, jobName, string(name))
+    `, string(name), value, jobName, value)
 	return err
 }
 
-// SeedDB seeds the database with initial data
-func SeedDB() error {
-	// TODO: Implement seeding logic of static tables like global metrics
+// SeedDB inserts the rows declared in db-seed.json, such as the global metrics. The seeds
+// skip rows that already exist, so they run on every start
+func (s *SQLStore) SeedDB() error {
+	var seeds struct {
+		Seeds []struct {
+			Name string `json:"name"`
+			SQL  string `json:"sql"`
+		} `json:"seeds"`
+	}
+	if err := json.Unmarshal(seedFile, &seeds); err != nil {
+		return fmt.Errorf("failed to parse db-seed.json: %w", err)
+	}
+
+	for _, seed := range seeds.Seeds {
+		res, err := s.exec(seed.SQL)
+		if err != nil {
+			return fmt.Errorf("failed to seed %q: %w", seed.Name, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			log.Printf("Seeded %d row(s) of %q\n", n, seed.Name)
+		}
+	}
 	return nil
 }
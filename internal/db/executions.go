@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"schedulerservice/internal/jobs"
+)
+
+// StartExecution stores exec, which identifies the job, attempt and trigger of a run, and sets its ID
+func (s *SQLStore) StartExecution(exec *jobs.Execution) error {
+	// RETURNING works on both SQLite and Postgres, whose driver has no LastInsertId
+	err := s.queryRow(`
+        INSERT INTO job_executions (job_id, job_name, attempt, trigger, status, started_at)
+        VALUES ((SELECT id FROM jobs WHERE name = ?), ?, ?, ?, ?, ?)
+        RETURNING id
+    `, exec.JobName, exec.JobName, exec.Attempt, string(exec.Trigger), string(exec.Status), exec.StartedAt).Scan(&exec.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert execution: %w", err)
+	}
+	return nil
+}
+
+// FinishExecution stores the final state of an execution
+func (s *SQLStore) FinishExecution(exec *jobs.Execution) error {
+	_, err := s.exec(`
+        UPDATE job_executions SET
+        status = ?, status_code = ?, duration = ?, error = ?, response_body = ?, finished_at = ?
+        WHERE id = ?
+    `, string(exec.Status), exec.StatusCode, exec.Duration, exec.Error, exec.ResponseBody, nullableTime(exec.FinishedAt), exec.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update execution %d: %w", exec.ID, err)
+	}
+	return nil
+}
+
+// ListExecutions returns a page of the execution history of a job, newest first,
+// along with the total number of executions matching the filter
+func (s *SQLStore) ListExecutions(name string, filter jobs.ExecutionFilter) ([]jobs.Execution, int, error) {
+	where := "WHERE job_name = ?"
+	args := []any{name}
+	if len(filter.Statuses) > 0 {
+		placeholders := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			placeholders[i] = "?"
+			args = append(args, string(status))
+		}
+		where += " AND status IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if filter.Trigger != "" {
+		where += " AND trigger = ?"
+		args = append(args, string(filter.Trigger))
+	}
+
+	var total int
+	if err := s.queryRow("SELECT COUNT(*) FROM job_executions "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count executions: %w", err)
+	}
+
+	rows, err := s.query(`
+        SELECT id, job_name, attempt, trigger, status, status_code, duration, error, response_body, started_at, finished_at
+        FROM job_executions `+where+`
+        ORDER BY started_at DESC, id DESC
+        LIMIT ? OFFSET ?
+    `, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load executions: %w", err)
+	}
+	defer rows.Close()
+
+	executions := make([]jobs.Execution, 0, filter.Limit)
+	for rows.Next() {
+		var (
+			exec         jobs.Execution
+			statusCode   sql.NullInt64
+			duration     sql.NullFloat64
+			errText      sql.NullString
+			responseBody sql.NullString
+			finishedAt   sql.NullTime
+		)
+		if err := rows.Scan(&exec.ID, &exec.JobName, &exec.Attempt, &exec.Trigger, &exec.Status, &statusCode, &duration,
+			&errText, &responseBody, &exec.StartedAt, &finishedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan execution: %w", err)
+		}
+		exec.StatusCode = int(statusCode.Int64)
+		exec.Duration = duration.Float64
+		exec.Error = errText.String
+		exec.ResponseBody = responseBody.String
+		if finishedAt.Valid {
+			exec.FinishedAt = &finishedAt.Time
+		}
+		executions = append(executions, exec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read executions: %w", err)
+	}
+	return executions, total, nil
+}
@@ -0,0 +1,297 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"schedulerservice/internal/jobs"
+)
+
+// jobColumns lists the columns of the jobs table read by scanJob
+const jobColumns = "name, type, cron, timezone, endpoint, method, headers, query, body, timeout_ms, retry_policy, concurrency, misfire_policy, misfire_limit, paused, run_at, completed_at, target, kafka_target"
+
+// jobStateColumns lists the columns of the jobs table holding the runtime state of a job
+const jobStateColumns = "last_status, last_duration, failure_streak, last_run_at"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// LoadJobs returns every stored job along with its runtime state
+func (s *SQLStore) LoadJobs() ([]jobs.StoredJob, error) {
+	rows, err := s.query("SELECT " + jobColumns + ", " + jobStateColumns + ", created_at FROM jobs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var stored []jobs.StoredJob
+	for rows.Next() {
+		var (
+			lastStatus   sql.NullString
+			lastDuration sql.NullFloat64
+			lastRunAt    sql.NullTime
+			createdAt    sql.NullTime
+			state        jobs.JobState
+		)
+		job, err := scanJob(rows, &lastStatus, &lastDuration, &state.FailureStreak, &lastRunAt, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		state.LastStatus = jobs.ExecutionStatus(lastStatus.String)
+		state.LastDuration = lastDuration.Float64
+		if lastRunAt.Valid {
+			state.LastRunAt = &lastRunAt.Time
+		}
+		stored = append(stored, jobs.StoredJob{Job: job, State: state, CreatedAt: createdAt.Time})
+	}
+	return stored, rows.Err()
+}
+
+// scanJob reads a job definition selected with jobColumns. Any extra destinations
+// receive the columns selected after jobColumns
+func scanJob(row rowScanner, extra ...any) (jobs.Job, error) {
+	var (
+		job       jobs.Job
+		jobType   sql.NullString
+		timezone  sql.NullString
+		method    sql.NullString
+		headers   sql.NullString
+		query     sql.NullString
+		body      sql.NullString
+		timeoutMs sql.NullInt64
+		retry     sql.NullString
+		policy    sql.NullString
+		misfire   sql.NullString
+		runAt     sql.NullTime
+		completed sql.NullTime
+		target    sql.NullString
+		kafka     sql.NullString
+	)
+	dest := []any{&job.Name, &jobType, &job.Cron, &timezone, &job.Endpoint, &method, &headers, &query, &body, &timeoutMs, &retry, &policy, &misfire, &job.MisfireLimit, &job.Paused, &runAt, &completed, &target, &kafka}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return job, err
+	}
+
+	job.Type = jobs.JobType(jobType.String)
+	job.Timezone = timezone.String
+	if runAt.Valid {
+		job.RunAt = &runAt.Time
+	}
+	if completed.Valid {
+		job.CompletedAt = &completed.Time
+	}
+	job.Method = method.String
+	job.Concurrency = jobs.ConcurrencyPolicy(policy.String)
+	job.Misfire = jobs.MisfirePolicy(misfire.String)
+	if err := unmarshalColumn(headers, &job.Headers); err != nil {
+		return job, fmt.Errorf("invalid headers for job %s: %w", job.Name, err)
+	}
+	if err := unmarshalColumn(query, &job.Query); err != nil {
+		return job, fmt.Errorf("invalid query for job %s: %w", job.Name, err)
+	}
+	if body.String != "" {
+		job.Body = json.RawMessage(body.String)
+	}
+	job.Timeout = jobs.Duration(time.Duration(timeoutMs.Int64) * time.Millisecond)
+	if retry.String != "" {
+		job.Retry = &jobs.RetryPolicy{}
+		if err := json.Unmarshal([]byte(retry.String), job.Retry); err != nil {
+			return job, fmt.Errorf("invalid retry policy for job %s: %w", job.Name, err)
+		}
+	}
+	job.Target = jobs.TargetType(target.String)
+	if kafka.String != "" {
+		job.Kafka = &jobs.KafkaTarget{}
+		if err := json.Unmarshal([]byte(kafka.String), job.Kafka); err != nil {
+			return job, fmt.Errorf("invalid kafka target for job %s: %w", job.Name, err)
+		}
+	}
+	return job, nil
+}
+
+// InsertJob stores a new job definition
+func (s *SQLStore) InsertJob(job jobs.Job) error {
+	values, err := jobValues(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(
+		"INSERT INTO jobs (name, type, cron, timezone, endpoint, method, headers, query, body, timeout_ms, retry_policy, concurrency, misfire_policy, misfire_limit, paused, run_at, completed_at, target, kafka_target) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		append([]any{job.Name}, values...)...,
+	)
+	return err
+}
+
+// UpdateJob replaces the stored definition of a job
+func (s *SQLStore) UpdateJob(job jobs.Job) error {
+	values, err := jobValues(job)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.exec(`
+        UPDATE jobs SET
+        type = ?, cron = ?, timezone = ?, endpoint = ?, method = ?, headers = ?, query = ?, body = ?, timeout_ms = ?,
+        retry_policy = ?, concurrency = ?, misfire_policy = ?, misfire_limit = ?, paused = ?, run_at = ?, completed_at = ?,
+        target = ?, kafka_target = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE name = ?
+    `, append(values, job.Name)...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("job %q %w", job.Name, jobs.ErrJobNotFound)
+	}
+	return nil
+}
+
+// DeleteJob removes a job definition
+func (s *SQLStore) DeleteJob(name string) error {
+	_, err := s.exec("DELETE FROM jobs WHERE name = ?", name)
+	return err
+}
+
+// jobValues returns the column values of a job definition, in the order of jobColumns without the name
+func jobValues(job jobs.Job) ([]any, error) {
+	headers, err := marshalColumn(job.Headers)
+	if err != nil {
+		return nil, err
+	}
+	query, err := marshalColumn(job.Query)
+	if err != nil {
+		return nil, err
+	}
+	var retry sql.NullString
+	if job.Retry != nil {
+		data, err := json.Marshal(job.Retry)
+		if err != nil {
+			return nil, err
+		}
+		retry = nullableString(string(data))
+	}
+	var kafka sql.NullString
+	if job.Kafka != nil {
+		data, err := json.Marshal(job.Kafka)
+		if err != nil {
+			return nil, err
+		}
+		kafka = nullableString(string(data))
+	}
+
+	return []any{
+		string(job.Type), job.Cron, nullableString(job.Timezone), job.Endpoint, job.Method, headers, query,
+		nullableString(string(job.Body)), time.Duration(job.Timeout).Milliseconds(), retry, string(job.Concurrency),
+		string(job.Misfire), job.MisfireLimit, job.Paused, nullableTime(job.RunAt), nullableTime(job.CompletedAt),
+		string(job.Target), kafka,
+	}, nil
+}
+
+// UpdateJobState stores the outcome of the latest run of a job
+func (s *SQLStore) UpdateJobState(name string, state jobs.JobState) error {
+	_, err := s.exec(
+		"UPDATE jobs SET last_status = ?, last_duration = ?, failure_streak = ? WHERE name = ?",
+		string(state.LastStatus), state.LastDuration, state.FailureStreak, name,
+	)
+	return err
+}
+
+// SetJobLastRun stores when the latest scheduled run of a job started
+func (s *SQLStore) SetJobLastRun(name string, lastRunAt time.Time) error {
+	_, err := s.exec("UPDATE jobs SET last_run_at = ? WHERE name = ?", lastRunAt.UTC(), name)
+	return err
+}
+
+// ClaimRun stores scheduledAt as the latest scheduled run of a job unless a run at or after
+// it is already stored. On Postgres the job row is locked while it is checked, so replicas
+// sharing the database wait for each other and only the first one claims the run
+func (s *SQLStore) ClaimRun(name string, scheduledAt time.Time) (bool, error) {
+	scheduledAt = scheduledAt.UTC()
+	if s.dialect != Postgres {
+		// SQLite locks the whole database for the write, so a conditional update is enough
+		res, err := s.exec(
+			"UPDATE jobs SET last_run_at = ? WHERE name = ? AND (last_run_at IS NULL OR last_run_at < ?)",
+			scheduledAt, name, scheduledAt,
+		)
+		if err != nil {
+			return false, err
+		}
+		n, err := res.RowsAffected()
+		return n > 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var lastRunAt sql.NullTime
+	err = tx.QueryRow(s.dialect.Rebind("SELECT last_run_at FROM jobs WHERE name = ? FOR UPDATE"), name).Scan(&lastRunAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("job %q %w", name, jobs.ErrJobNotFound)
+	}
+	if err != nil {
+		return false, err
+	}
+	if lastRunAt.Valid && !lastRunAt.Time.Before(scheduledAt) {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(s.dialect.Rebind("UPDATE jobs SET last_run_at = ? WHERE name = ?"), scheduledAt, name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// SetJobCompleted marks a one-shot job as done
+func (s *SQLStore) SetJobCompleted(name string, completedAt time.Time) error {
+	_, err := s.exec(
+		"UPDATE jobs SET completed_at = ?, updated_at = CURRENT_TIMESTAMP WHERE name = ?",
+		completedAt, name,
+	)
+	return err
+}
+
+// SetJobPaused stores the paused state of a job
+func (s *SQLStore) SetJobPaused(name string, paused bool) error {
+	_, err := s.exec(
+		"UPDATE jobs SET paused = ?, updated_at = CURRENT_TIMESTAMP WHERE name = ?",
+		paused, name,
+	)
+	return err
+}
+
+// marshalColumn encodes a map as JSON text, storing NULL for empty maps
+func marshalColumn(value map[string]string) (sql.NullString, error) {
+	if len(value) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func unmarshalColumn(column sql.NullString, dest *map[string]string) error {
+	if !column.Valid || column.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(column.String), dest)
+}
+
+func nullableString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullableTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: value.UTC(), Valid: true}
+}
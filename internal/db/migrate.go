@@ -0,0 +1,216 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the schema migrations of every dialect, one directory per dialect
+// named NNNN_description.sql. Migrations are applied in version order and never rolled back,
+// so a released migration must not be edited: changes go in a new file
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockID identifies the Postgres advisory lock taken while migrating, so replicas
+// starting together apply each migration once
+const migrationLockID = 5_016
+
+// migration is a versioned change of the database schema
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus tells whether a migration has been applied to the database
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// migrations returns the migrations of the dialect, sorted by version
+func (d Dialect) migrations() ([]migration, error) {
+	dir := path.Join("migrations", d.migrationsDir())
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	seen := make(map[int]string, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		version, name, err := parseMigrationName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// migrationsDir returns the directory of migrationFiles holding the migrations of the dialect
+func (d Dialect) migrationsDir() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// parseMigrationName splits a file name such as 0002_metrics_job_name.sql into its version and name
+func parseMigrationName(file string) (int, string, error) {
+	prefix, name, found := strings.Cut(strings.TrimSuffix(file, ".sql"), "_")
+	version, err := strconv.Atoi(prefix)
+	if !found || err != nil || version <= 0 || name == "" {
+		return 0, "", fmt.Errorf("migration %s is not named NNNN_description.sql", file)
+	}
+	return version, name, nil
+}
+
+// Migrate applies the migrations the database has not seen yet, in version order, then
+// seeds it. Each migration runs in its own transaction along with its schema_migrations row,
+// so a failed migration leaves no trace and is retried on the next start
+func (s *SQLStore) Migrate() error {
+	migrations, err := s.dialect.migrations()
+	if err != nil {
+		return err
+	}
+	if err := s.createMigrationsTable(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		applied := false
+		err := s.inMigrationTx(func(tx *sql.Tx) error {
+			var exists int
+			err := tx.QueryRow(s.dialect.Rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), m.Version).Scan(&exists)
+			if err != nil || exists > 0 {
+				return err
+			}
+			if _, err := tx.Exec(m.SQL); err != nil {
+				return err
+			}
+			_, err = tx.Exec(
+				s.dialect.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+				m.Version, m.Name, time.Now().UTC(),
+			)
+			applied = err == nil
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if applied {
+			log.Printf("Applied migration %04d_%s\n", m.Version, m.Name)
+		}
+	}
+	return s.SeedDB()
+}
+
+// MigrationStatus returns every migration known to the service or recorded in the database,
+// sorted by version. Pending migrations have no AppliedAt
+func (s *SQLStore) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := s.dialect.migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.createMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query("SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	for rows.Next() {
+		var (
+			status    MigrationStatus
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&status.Version, &status.Name, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		status.AppliedAt = &appliedAt
+		applied[status.Version] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if done, exists := applied[m.Version]; exists {
+			status.AppliedAt = done.AppliedAt
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	// Migrations applied by a newer version of the service
+	for _, status := range applied {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// createMigrationsTable creates the table recording the applied migrations if needed
+func (s *SQLStore) createMigrationsTable() error {
+	err := s.inMigrationTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// inMigrationTx runs fn in a transaction holding the migration lock. On SQLite transactions
+// take the write lock as they begin, which serializes them on their own
+func (s *SQLStore) inMigrationTx(fn func(*sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if s.dialect == Postgres {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
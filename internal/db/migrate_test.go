@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"schedulerservice/internal/jobs"
+)
+
+// baselineSQLite creates a SQLite database with the schema db-tables.json used to create,
+// before there were migrations, and returns its path
+func baselineSQLite(t *testing.T, rows ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	conn, err := sql.Open(string(SQLite), path)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer conn.Close()
+
+	migrations, err := SQLite.migrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	// 0001_initial is that schema
+	for _, statement := range append([]string{migrations[0].SQL}, rows...) {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatalf("failed to build the baseline database: %v", err)
+		}
+	}
+	return path
+}
+
+func TestMigrateBaselineWithDuplicateNames(t *testing.T) {
+	// The baseline service inserted every job again on each start
+	path := baselineSQLite(t,
+		"INSERT INTO jobs (id, name, cron, endpoint) VALUES (1, 'ping', '*/10 * * * * *', 'http://localhost:3000/old')",
+		"INSERT INTO jobs (id, name, cron, endpoint) VALUES (2, 'report', '0 6 * * *', 'http://localhost:3000/report')",
+		"INSERT INTO jobs (id, name, cron, endpoint) VALUES (3, 'ping', '*/10 * * * * *', 'http://localhost:3000/ping')",
+		"INSERT INTO job_executions (job_id, status) VALUES (1, 'success'), (3, 'failed'), (2, 'success')",
+	)
+
+	store := openStore(t, SQLite, path)
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	stored, err := store.LoadJobs()
+	if err != nil {
+		t.Fatalf("LoadJobs: %v", err)
+	}
+	endpoints := make(map[string]string)
+	for _, s := range stored {
+		endpoints[s.Job.Name] = s.Job.Endpoint
+	}
+	if len(stored) != 2 || endpoints["ping"] != "http://localhost:3000/ping" || endpoints["report"] == "" {
+		t.Errorf("LoadJobs returned %v, want one row per name with the newest ping", endpoints)
+	}
+
+	// The executions of the dropped row belong to the kept one
+	tests := []struct {
+		name  string
+		total int
+	}{
+		{name: "ping", total: 2},
+		{name: "report", total: 1},
+	}
+	for _, tt := range tests {
+		if _, total, err := store.ListExecutions(tt.name, jobs.ExecutionFilter{Limit: 10}); err != nil || total != tt.total {
+			t.Errorf("ListExecutions(%s) returned %d (%v), want %d", tt.name, total, err, tt.total)
+		}
+	}
+
+	if err := store.InsertJob(jobs.Job{Name: "ping", Cron: "* * * * *", Endpoint: "http://localhost:3000/ping"}); err == nil {
+		t.Errorf("InsertJob of a duplicate name succeeded after the migration")
+	}
+}
@@ -0,0 +1,5 @@
+-- Metrics are kept per job, with 'global' standing for the service-wide ones. The unique
+-- index is the conflict target of the upsert adding to a metric
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS job_name TEXT NOT NULL DEFAULT 'global';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metrics_name_job ON metrics (metric_name, job_name);
@@ -0,0 +1,5 @@
+-- Outcome of the latest run of each job, and start of its latest scheduled run
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS last_status TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS last_duration DOUBLE PRECISION;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS failure_streak INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS last_run_at TIMESTAMPTZ;
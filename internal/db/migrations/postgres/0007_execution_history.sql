@@ -0,0 +1,13 @@
+-- Details of every execution attempt, looked up by job name. The executions recorded before
+-- take the name of their job
+ALTER TABLE job_executions ADD COLUMN IF NOT EXISTS job_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE job_executions ADD COLUMN IF NOT EXISTS attempt INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE job_executions ADD COLUMN IF NOT EXISTS trigger TEXT NOT NULL DEFAULT 'scheduled';
+ALTER TABLE job_executions ADD COLUMN IF NOT EXISTS status_code INTEGER;
+ALTER TABLE job_executions ADD COLUMN IF NOT EXISTS duration DOUBLE PRECISION;
+ALTER TABLE job_executions ADD COLUMN IF NOT EXISTS error TEXT;
+ALTER TABLE job_executions ADD COLUMN IF NOT EXISTS response_body TEXT;
+
+UPDATE job_executions SET job_name = jobs.name FROM jobs WHERE jobs.id = job_executions.job_id AND job_executions.job_name = '';
+
+CREATE INDEX IF NOT EXISTS idx_job_executions_job_name ON job_executions (job_name, started_at);
@@ -0,0 +1,4 @@
+-- Executions outlive their job, so job_id becomes optional and is cleared when the job is deleted
+ALTER TABLE job_executions ALTER COLUMN job_id DROP NOT NULL;
+ALTER TABLE job_executions DROP CONSTRAINT IF EXISTS job_executions_job_id_fkey;
+ALTER TABLE job_executions ADD CONSTRAINT job_executions_job_id_fkey FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+-- Leases taken by the instances competing to be the leader, previously created by the leader package
+CREATE TABLE IF NOT EXISTS leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at BIGINT NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Jobs either make an HTTP request or produce a Kafka message, described as JSON in kafka_target
+ALTER TABLE jobs ADD COLUMN target TEXT NOT NULL DEFAULT 'http';
+ALTER TABLE jobs ADD COLUMN kafka_target TEXT;
@@ -0,0 +1,25 @@
+-- Settings of the job definitions: the request, the retry, concurrency and misfire policies,
+-- the paused state and the one-shot schedule. Job names are unique
+ALTER TABLE jobs ADD COLUMN type TEXT NOT NULL DEFAULT 'cron';
+ALTER TABLE jobs ADD COLUMN timezone TEXT;
+ALTER TABLE jobs ADD COLUMN method TEXT NOT NULL DEFAULT 'GET';
+ALTER TABLE jobs ADD COLUMN headers TEXT;
+ALTER TABLE jobs ADD COLUMN query TEXT;
+ALTER TABLE jobs ADD COLUMN body TEXT;
+ALTER TABLE jobs ADD COLUMN timeout_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN retry_policy TEXT;
+ALTER TABLE jobs ADD COLUMN concurrency TEXT NOT NULL DEFAULT 'allow';
+ALTER TABLE jobs ADD COLUMN misfire_policy TEXT NOT NULL DEFAULT 'ignore';
+ALTER TABLE jobs ADD COLUMN misfire_limit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN paused BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN run_at DATETIME;
+ALTER TABLE jobs ADD COLUMN completed_at DATETIME;
+
+-- The service used to insert every job again on each start, so older databases hold several
+-- rows per name. The newest row of each name is kept, and the executions of the others move to it
+UPDATE job_executions SET job_id = (
+    SELECT MAX(kept.id) FROM jobs kept JOIN jobs dropped ON dropped.name = kept.name WHERE dropped.id = job_executions.job_id
+) WHERE job_id IN (SELECT id FROM jobs WHERE id NOT IN (SELECT MAX(id) FROM jobs GROUP BY name));
+DELETE FROM jobs WHERE id NOT IN (SELECT MAX(id) FROM jobs GROUP BY name);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_name ON jobs (name);
@@ -0,0 +1,5 @@
+-- Outcome of the latest run of each job, and start of its latest scheduled run
+ALTER TABLE jobs ADD COLUMN last_status TEXT;
+ALTER TABLE jobs ADD COLUMN last_duration REAL;
+ALTER TABLE jobs ADD COLUMN failure_streak INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN last_run_at DATETIME;
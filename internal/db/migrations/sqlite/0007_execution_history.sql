@@ -0,0 +1,13 @@
+-- Details of every execution attempt, looked up by job name. The executions recorded before
+-- take the name of their job
+ALTER TABLE job_executions ADD COLUMN job_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE job_executions ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE job_executions ADD COLUMN trigger TEXT NOT NULL DEFAULT 'scheduled';
+ALTER TABLE job_executions ADD COLUMN status_code INTEGER;
+ALTER TABLE job_executions ADD COLUMN duration REAL;
+ALTER TABLE job_executions ADD COLUMN error TEXT;
+ALTER TABLE job_executions ADD COLUMN response_body TEXT;
+
+UPDATE job_executions SET job_name = COALESCE((SELECT name FROM jobs WHERE jobs.id = job_executions.job_id), '') WHERE job_name = '';
+
+CREATE INDEX IF NOT EXISTS idx_job_executions_job_name ON job_executions (job_name, started_at);
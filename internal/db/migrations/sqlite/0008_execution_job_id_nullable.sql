@@ -0,0 +1,25 @@
+-- Executions outlive their job, so job_id becomes optional. SQLite cannot drop a NOT NULL
+-- constraint in place, so the table is rebuilt with its rows
+CREATE TABLE job_executions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER,
+    job_name TEXT NOT NULL DEFAULT '',
+    attempt INTEGER NOT NULL DEFAULT 1,
+    trigger TEXT NOT NULL DEFAULT 'scheduled',
+    status TEXT NOT NULL,
+    status_code INTEGER,
+    duration REAL,
+    error TEXT,
+    response_body TEXT,
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY(job_id) REFERENCES jobs(id)
+);
+
+INSERT INTO job_executions_new (id, job_id, job_name, attempt, trigger, status, status_code, duration, error, response_body, started_at, finished_at)
+SELECT id, job_id, job_name, attempt, trigger, status, status_code, duration, error, response_body, started_at, finished_at FROM job_executions;
+
+DROP TABLE job_executions;
+ALTER TABLE job_executions_new RENAME TO job_executions;
+
+CREATE INDEX IF NOT EXISTS idx_job_executions_job_name ON job_executions (job_name, started_at);
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"schedulerservice/internal/jobs"
+)
+
+// postgresDSN creates a schema of its own in the Postgres database at DATABASE_URL, dropped
+// at the end of the test, and returns a DSN whose connections use it. It skips the test
+// when DATABASE_URL is unset
+func postgresDSN(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open(string(Postgres), dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("scheduler_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("failed to create schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	dsn, err = withSearchPath(dsn, schema)
+	if err != nil {
+		t.Fatalf("invalid DATABASE_URL: %v", err)
+	}
+	return dsn
+}
+
+// withSearchPath makes the connections of a URL or key=value DSN use schema
+func withSearchPath(dsn, schema string) (string, error) {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func TestPostgresMigrateWaitsForLock(t *testing.T) {
+	dsn := postgresDSN(t)
+	store := openStore(t, Postgres, dsn)
+	other := openStore(t, Postgres, dsn)
+
+	// Another replica holds the migration lock
+	tx, err := other.DB().Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		t.Fatalf("failed to take migration lock: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- store.Migrate() }()
+	select {
+	case err := <-done:
+		t.Fatalf("Migrate returned %v while another replica held the lock", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Migrate did not finish once the lock was released")
+	}
+}
+
+func TestPostgresClaimRunWaitsForRowLock(t *testing.T) {
+	dsn := postgresDSN(t)
+	store := openStore(t, Postgres, dsn)
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	other := openStore(t, Postgres, dsn)
+	if err := store.InsertJob(jobs.Job{Name: "ping", Cron: "* * * * *", Endpoint: "http://localhost:3000/ping"}); err != nil {
+		t.Fatalf("InsertJob: %v", err)
+	}
+
+	// Another replica is claiming a later run and holds the job row
+	scheduledAt := time.Now().UTC().Truncate(time.Second)
+	tx, err := other.DB().Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SELECT last_run_at FROM jobs WHERE name = $1 FOR UPDATE", "ping"); err != nil {
+		t.Fatalf("failed to lock job row: %v", err)
+	}
+
+	type result struct {
+		claimed bool
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		claimed, err := store.ClaimRun("ping", scheduledAt)
+		done <- result{claimed, err}
+	}()
+	select {
+	case res := <-done:
+		t.Fatalf("ClaimRun returned %v, %v while another replica held the job row", res.claimed, res.err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if _, err := tx.Exec("UPDATE jobs SET last_run_at = $1 WHERE name = $2", scheduledAt.Add(time.Minute), "ping"); err != nil {
+		t.Fatalf("failed to store the later run: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("ClaimRun: %v", res.err)
+		}
+		if res.claimed {
+			t.Errorf("ClaimRun claimed a run older than the one stored while it waited")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ClaimRun did not finish once the row lock was released")
+	}
+}
@@ -0,0 +1,443 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"schedulerservice/internal/jobs"
+	"schedulerservice/internal/metrics"
+)
+
+// storeOpener opens another store on the database under test, closed at the end of the test
+type storeOpener func(t *testing.T) *SQLStore
+
+// forEachDialect runs test against a SQLite database in a temporary directory and, when
+// DATABASE_URL is set, against a schema of its own in that Postgres database
+func forEachDialect(t *testing.T, test func(t *testing.T, open storeOpener)) {
+	t.Run("sqlite", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jobs.db")
+		test(t, func(t *testing.T) *SQLStore { return openStore(t, SQLite, path) })
+	})
+	t.Run("postgres", func(t *testing.T) {
+		dsn := postgresDSN(t)
+		test(t, func(t *testing.T) *SQLStore { return openStore(t, Postgres, dsn) })
+	})
+}
+
+// openStore opens a store on the database at dsn, closed at the end of the test
+func openStore(t *testing.T, dialect Dialect, dsn string) *SQLStore {
+	t.Helper()
+	store, err := Open(dialect, dsn)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// migrated opens a store on the database under test with the schema up to date
+func migrated(t *testing.T, open storeOpener) *SQLStore {
+	t.Helper()
+	store := open(t)
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return store
+}
+
+func TestMigrate(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, open storeOpener) {
+		store := migrated(t, open)
+
+		// Applying the migrations again finds nothing to do
+		if err := store.Migrate(); err != nil {
+			t.Fatalf("second Migrate: %v", err)
+		}
+		statuses, err := store.MigrationStatus()
+		if err != nil {
+			t.Fatalf("MigrationStatus: %v", err)
+		}
+		migrations, _ := store.Dialect().migrations()
+		if len(statuses) != len(migrations) {
+			t.Fatalf("%d migrations recorded, want %d", len(statuses), len(migrations))
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				t.Errorf("migration %04d_%s is not applied", status.Version, status.Name)
+			}
+		}
+
+		stored, err := store.LoadMetrics()
+		if err != nil {
+			t.Fatalf("LoadMetrics: %v", err)
+		}
+		seeded := make(map[metrics.MetricName]bool)
+		for _, metric := range stored {
+			if metric.JobName == "" {
+				seeded[metric.Name] = true
+			}
+		}
+		for _, name := range jobs.GlobalMetrics {
+			if !seeded[name] {
+				t.Errorf("global metric %s has no row after seeding", name)
+			}
+		}
+	})
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, open storeOpener) {
+		// Replicas starting together all migrate the same database
+		const replicas = 4
+		stores := make([]*SQLStore, replicas)
+		for i := range stores {
+			stores[i] = open(t)
+		}
+		var wg sync.WaitGroup
+		errs := make([]error, replicas)
+		for i, store := range stores {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = store.Migrate()
+			}()
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("Migrate of replica %d: %v", i, err)
+			}
+		}
+
+		var applied, versions int
+		err := stores[0].queryRow("SELECT COUNT(*), COUNT(DISTINCT version) FROM schema_migrations").Scan(&applied, &versions)
+		if err != nil {
+			t.Fatalf("failed to count applied migrations: %v", err)
+		}
+		migrations, _ := stores[0].Dialect().migrations()
+		if applied != len(migrations) || versions != len(migrations) {
+			t.Errorf("%d migration rows for %d versions, want each of the %d migrations applied once", applied, versions, len(migrations))
+		}
+	})
+}
+
+func TestJobs(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, open storeOpener) {
+		store := migrated(t, open)
+
+		runAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		job := jobs.Job{
+			Name:        "report",
+			Type:        jobs.JobTypeOnce,
+			RunAt:       &runAt,
+			Timezone:    "Europe/Paris",
+			Endpoint:    "http://localhost:3000/report",
+			Method:      "POST",
+			Headers:     map[string]string{"Authorization": "Bearer secret"},
+			Query:       map[string]string{"full": "true"},
+			Body:        []byte(`{"format":"pdf"}`),
+			Timeout:     jobs.Duration(30 * time.Second),
+			Retry:       &jobs.RetryPolicy{MaxAttempts: 3, InitialDelay: jobs.Duration(time.Second)},
+			Concurrency: jobs.ConcurrencySkip,
+			Misfire:     jobs.MisfireRunOnce,
+			Target:      jobs.TargetHTTP,
+		}
+		if err := store.InsertJob(job); err != nil {
+			t.Fatalf("InsertJob: %v", err)
+		}
+		if err := store.InsertJob(job); err == nil {
+			t.Errorf("InsertJob of a duplicate name succeeded")
+		}
+
+		stored := loadJob(t, store, "report")
+		if stored.Method != "POST" || stored.Headers["Authorization"] != "Bearer secret" || stored.Query["full"] != "true" ||
+			string(stored.Body) != `{"format":"pdf"}` || stored.Timeout != job.Timeout || stored.Retry == nil ||
+			stored.Retry.MaxAttempts != 3 || stored.Concurrency != jobs.ConcurrencySkip || stored.Misfire != jobs.MisfireRunOnce ||
+			stored.RunAt == nil || !stored.RunAt.Equal(runAt) {
+			t.Errorf("LoadJobs returned %+v, want the inserted definition", stored)
+		}
+
+		job.Method = "PUT"
+		job.Headers = nil
+		job.Paused = true
+		if err := store.UpdateJob(job); err != nil {
+			t.Fatalf("UpdateJob: %v", err)
+		}
+		stored = loadJob(t, store, "report")
+		if stored.Method != "PUT" || len(stored.Headers) != 0 || !stored.Paused {
+			t.Errorf("LoadJobs returned %+v, want the updated definition", stored)
+		}
+		if err := store.UpdateJob(jobs.Job{Name: "missing"}); !errors.Is(err, jobs.ErrJobNotFound) {
+			t.Errorf("UpdateJob of an unknown job returned %v, want ErrJobNotFound", err)
+		}
+
+		if err := store.SetJobPaused("report", false); err != nil {
+			t.Fatalf("SetJobPaused: %v", err)
+		}
+		completedAt := time.Now().UTC().Truncate(time.Second)
+		if err := store.SetJobCompleted("report", completedAt); err != nil {
+			t.Fatalf("SetJobCompleted: %v", err)
+		}
+		state := jobs.JobState{LastStatus: jobs.ExecutionFailed, LastDuration: 1.5, FailureStreak: 2}
+		if err := store.UpdateJobState("report", state); err != nil {
+			t.Fatalf("UpdateJobState: %v", err)
+		}
+		all, err := store.LoadJobs()
+		if err != nil || len(all) != 1 {
+			t.Fatalf("LoadJobs returned %d jobs (%v), want 1", len(all), err)
+		}
+		if all[0].Job.Paused || all[0].Job.CompletedAt == nil || !all[0].Job.CompletedAt.Equal(completedAt) {
+			t.Errorf("LoadJobs returned paused=%v completed at %v, want resumed and completed", all[0].Job.Paused, all[0].Job.CompletedAt)
+		}
+		if got := all[0].State; got.LastStatus != state.LastStatus || got.LastDuration != state.LastDuration || got.FailureStreak != state.FailureStreak {
+			t.Errorf("LoadJobs returned state %+v, want %+v", got, state)
+		}
+		if all[0].CreatedAt.IsZero() {
+			t.Errorf("LoadJobs returned no creation time")
+		}
+
+		if err := store.DeleteJob("report"); err != nil {
+			t.Fatalf("DeleteJob: %v", err)
+		}
+		if all, _ := store.LoadJobs(); len(all) != 0 {
+			t.Errorf("LoadJobs returned %d jobs after DeleteJob, want none", len(all))
+		}
+	})
+}
+
+func loadJob(t *testing.T, store *SQLStore, name string) jobs.Job {
+	t.Helper()
+	all, err := store.LoadJobs()
+	if err != nil {
+		t.Fatalf("LoadJobs: %v", err)
+	}
+	for _, stored := range all {
+		if stored.Job.Name == name {
+			return stored.Job
+		}
+	}
+	t.Fatalf("LoadJobs did not return job %s", name)
+	return jobs.Job{}
+}
+
+func TestExecutions(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, open storeOpener) {
+		store := migrated(t, open)
+		if err := store.InsertJob(jobs.Job{Name: "ping", Cron: "* * * * *", Endpoint: "http://localhost:3000/ping"}); err != nil {
+			t.Fatalf("InsertJob: %v", err)
+		}
+
+		start := time.Now().UTC().Truncate(time.Second)
+		for i, status := range []jobs.ExecutionStatus{jobs.ExecutionFailed, jobs.ExecutionSucceeded} {
+			exec := &jobs.Execution{
+				JobName:   "ping",
+				Attempt:   i + 1,
+				Trigger:   jobs.TriggerManual,
+				Status:    jobs.ExecutionRunning,
+				StartedAt: start.Add(time.Duration(i) * time.Second),
+			}
+			if err := store.StartExecution(exec); err != nil {
+				t.Fatalf("StartExecution: %v", err)
+			}
+			if exec.ID == 0 {
+				t.Fatalf("StartExecution did not set the execution ID")
+			}
+			finishedAt := exec.StartedAt.Add(500 * time.Millisecond)
+			exec.Status = status
+			exec.StatusCode = 200
+			exec.Duration = 0.5
+			exec.FinishedAt = &finishedAt
+			if err := store.FinishExecution(exec); err != nil {
+				t.Fatalf("FinishExecution: %v", err)
+			}
+		}
+
+		executions, total, err := store.ListExecutions("ping", jobs.ExecutionFilter{Limit: 10})
+		if err != nil {
+			t.Fatalf("ListExecutions: %v", err)
+		}
+		if total != 2 || len(executions) != 2 || executions[0].Attempt != 2 || executions[0].Status != jobs.ExecutionSucceeded {
+			t.Errorf("ListExecutions returned %d of %d executions %+v, want both, newest first", len(executions), total, executions)
+		}
+		_, total, err = store.ListExecutions("ping", jobs.ExecutionFilter{Statuses: []jobs.ExecutionStatus{jobs.ExecutionFailed}, Limit: 10})
+		if err != nil || total != 1 {
+			t.Errorf("ListExecutions of failed runs returned %d (%v), want 1", total, err)
+		}
+
+		// The history outlives the job
+		if err := store.DeleteJob("ping"); err != nil {
+			t.Fatalf("DeleteJob: %v", err)
+		}
+		if _, total, err := store.ListExecutions("ping", jobs.ExecutionFilter{Limit: 10}); err != nil || total != 2 {
+			t.Errorf("ListExecutions after DeleteJob returned %d (%v), want 2", total, err)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, open storeOpener) {
+		store := migrated(t, open)
+
+		if err := store.AddGlobalMetric(metrics.TotalExecutions, 2); err != nil {
+			t.Fatalf("AddGlobalMetric: %v", err)
+		}
+		for range 2 {
+			if err := store.AddJobMetric(metrics.TotalExecutions, "ping", 1.5); err != nil {
+				t.Fatalf("AddJobMetric: %v", err)
+			}
+		}
+
+		stored, err := store.LoadMetrics()
+		if err != nil {
+			t.Fatalf("LoadMetrics: %v", err)
+		}
+		values := make(map[string]float64)
+		for _, metric := range stored {
+			values[string(metric.Name)+"/"+metric.JobName] = metric.Value
+		}
+		if got := values[string(metrics.TotalExecutions)+"/"]; got != 2 {
+			t.Errorf("global %s is %v, want 2", metrics.TotalExecutions, got)
+		}
+		if got := values[string(metrics.TotalExecutions)+"/ping"]; got != 3 {
+			t.Errorf("%s of job ping is %v, want 3", metrics.TotalExecutions, got)
+		}
+	})
+}
+
+func TestClaimRun(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, open storeOpener) {
+		store := migrated(t, open)
+		if err := store.InsertJob(jobs.Job{Name: "ping", Cron: "* * * * *", Endpoint: "http://localhost:3000/ping"}); err != nil {
+			t.Fatalf("InsertJob: %v", err)
+		}
+
+		scheduledAt := time.Now().UTC().Truncate(time.Second)
+		claims := []struct {
+			at   time.Time
+			want bool
+		}{
+			{at: scheduledAt, want: true},
+			{at: scheduledAt, want: false},
+			{at: scheduledAt.Add(-time.Minute), want: false},
+			{at: scheduledAt.Add(time.Minute), want: true},
+		}
+		for _, claim := range claims {
+			claimed, err := store.ClaimRun("ping", claim.at)
+			if err != nil {
+				t.Fatalf("ClaimRun(%s): %v", claim.at.Format(time.RFC3339), err)
+			}
+			if claimed != claim.want {
+				t.Errorf("ClaimRun(%s) = %v, want %v", claim.at.Format(time.RFC3339), claimed, claim.want)
+			}
+		}
+
+		// SQLite reports an unknown job as a run it did not claim
+		claimed, err := store.ClaimRun("missing", scheduledAt)
+		if store.Dialect() == Postgres && !errors.Is(err, jobs.ErrJobNotFound) {
+			t.Errorf("ClaimRun of an unknown job returned %v, want ErrJobNotFound", err)
+		}
+		if claimed {
+			t.Errorf("ClaimRun claimed a run of an unknown job")
+		}
+	})
+}
+
+func TestClaimRunConcurrently(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, open storeOpener) {
+		migrated(t, open)
+
+		const replicas = 8
+		stores := make([]*SQLStore, replicas)
+		for i := range stores {
+			stores[i] = open(t)
+		}
+		if err := stores[0].InsertJob(jobs.Job{Name: "ping", Cron: "* * * * *", Endpoint: "http://localhost:3000/ping"}); err != nil {
+			t.Fatalf("InsertJob: %v", err)
+		}
+
+		// Every replica fires the same scheduled run
+		scheduledAt := time.Now().UTC().Truncate(time.Second)
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			claimed int
+		)
+		for _, store := range stores {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := store.ClaimRun("ping", scheduledAt)
+				if err != nil {
+					t.Errorf("ClaimRun: %v", err)
+					return
+				}
+				if ok {
+					mu.Lock()
+					claimed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if claimed != 1 {
+			t.Errorf("%d replicas claimed the run, want 1", claimed)
+		}
+	})
+}
+
+func TestSeedDB(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, open storeOpener) {
+		store := migrated(t, open)
+		globals := func() map[metrics.MetricName][]float64 {
+			t.Helper()
+			stored, err := store.LoadMetrics()
+			if err != nil {
+				t.Fatalf("LoadMetrics: %v", err)
+			}
+			values := make(map[metrics.MetricName][]float64)
+			for _, metric := range stored {
+				if metric.JobName == "" {
+					values[metric.Name] = append(values[metric.Name], metric.Value)
+				}
+			}
+			return values
+		}
+
+		if err := store.AddGlobalMetric(metrics.TotalJobs, 3); err != nil {
+			t.Fatalf("AddGlobalMetric: %v", err)
+		}
+		// A row deleted by hand makes its metric fail until the next start seeds it again
+		if _, err := store.DB().Exec("DELETE FROM metrics WHERE metric_name = 'jobs_active' AND job_name = 'global'"); err != nil {
+			t.Fatalf("failed to delete a seeded row: %v", err)
+		}
+		if err := store.AddGlobalMetric(metrics.ActiveJobs, 1); err == nil {
+			t.Errorf("AddGlobalMetric succeeded without a row to update")
+		}
+
+		// Seeding again restores the missing row and keeps the existing ones
+		if err := store.SeedDB(); err != nil {
+			t.Fatalf("SeedDB: %v", err)
+		}
+		if err := store.SeedDB(); err != nil {
+			t.Fatalf("second SeedDB: %v", err)
+		}
+		values := globals()
+		for _, name := range jobs.GlobalMetrics {
+			if len(values[name]) != 1 {
+				t.Errorf("global metric %s has %d row(s), want 1", name, len(values[name]))
+			}
+		}
+		if got := values[metrics.TotalJobs]; len(got) != 1 || got[0] != 3 {
+			t.Errorf("seeding again changed %s to %v, want it kept at 3", metrics.TotalJobs, got)
+		}
+		if got := values[metrics.ActiveJobs]; len(got) != 1 || got[0] != 0 {
+			t.Errorf("reseeded %s is %v, want 0", metrics.ActiveJobs, got)
+		}
+		if err := store.AddGlobalMetric(metrics.ActiveJobs, 1); err != nil {
+			t.Errorf("AddGlobalMetric after reseeding: %v", err)
+		}
+	})
+}
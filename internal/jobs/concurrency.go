@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"schedulerservice/internal/metrics"
+)
+
+// ConcurrencyPolicy decides what happens when a job is due while a previous run is still in progress
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow starts the new run alongside the running one
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencySkip drops the new run
+	ConcurrencySkip ConcurrencyPolicy = "skip"
+	// ConcurrencyQueue starts the new run once the running one finishes, keeping at most one run waiting
+	ConcurrencyQueue ConcurrencyPolicy = "queue"
+	// ConcurrencyReplace cancels the running run and starts the new one
+	ConcurrencyReplace ConcurrencyPolicy = "replace"
+)
+
+// validateConcurrency normalizes the concurrency policy of a job, defaulting to allow
+func validateConcurrency(job *Job) error {
+	job.Concurrency = ConcurrencyPolicy(strings.ToLower(strings.TrimSpace(string(job.Concurrency))))
+	switch job.Concurrency {
+	case "":
+		job.Concurrency = ConcurrencyAllow
+	case ConcurrencyAllow, ConcurrencySkip, ConcurrencyQueue, ConcurrencyReplace:
+	default:
+		return fmt.Errorf("invalid concurrency policy %q", job.Concurrency)
+	}
+	return nil
+}
+
+// run starts a run of the entry's job, applying its concurrency policy. Once the
+// manager is shutting down, no new run starts
+func (jm *JobManager) run(entry *jobEntry, trigger Trigger) {
+	if !jm.beginRun() {
+		entry.mu.Lock()
+		name := entry.job.Name
+		entry.mu.Unlock()
+		log.Printf("[WARN] Not starting %s run of job %s: shutting down", trigger, name)
+		return
+	}
+	jm.runCounted(entry, trigger)
+}
+
+// runCounted makes a run already counted by beginRun
+func (jm *JobManager) runCounted(entry *jobEntry, trigger Trigger) {
+	defer jm.runs.Done()
+	entry.mu.Lock()
+	job := entry.job
+	entry.mu.Unlock()
+
+	switch trigger {
+	case TriggerScheduled:
+		if !jm.claimRun(entry, job.Name) {
+			return
+		}
+	case TriggerMisfire:
+		jm.markRun(entry, job.Name)
+	}
+
+	if job.Concurrency != ConcurrencyAllow {
+		if !entry.acquire(job.Concurrency) {
+			jm.skip(job, trigger)
+			if job.Type == JobTypeOnce && trigger == TriggerScheduled {
+				jm.complete(entry, job.Name)
+			}
+			return
+		}
+		defer entry.release()
+	}
+
+	ctx, cancel := context.WithCancel(jm.runCtx)
+	defer entry.track(cancel)()
+
+	status, duration := jm.execute(ctx, job, trigger)
+	jm.finish(entry, job.Name, status, duration)
+	if job.Type == JobTypeOnce && trigger == TriggerScheduled {
+		jm.complete(entry, job.Name)
+	}
+}
+
+// finish stores the outcome of a run as the latest state of the job. Skipped runs never get here
+func (jm *JobManager) finish(e *jobEntry, name string, status ExecutionStatus, duration float64) {
+	e.mu.Lock()
+	e.state.LastStatus = status
+	e.state.LastDuration = duration
+	switch status {
+	case ExecutionSucceeded:
+		e.state.FailureStreak = 0
+	case ExecutionFailed:
+		e.state.FailureStreak++
+	}
+	state := e.state
+	e.mu.Unlock()
+
+	if err := jm.store.UpdateJobState(name, state); err != nil {
+		log.Printf("[ERROR] Failed to store state of job %s: %v", name, err)
+	}
+}
+
+// claimRun claims the scheduled run of the job due now, so that no other instance sharing
+// the store makes it as well, and stores it as the latest scheduled run. It reports whether
+// the run should go ahead; if the claim cannot be checked, the run goes ahead anyway
+func (jm *JobManager) claimRun(e *jobEntry, name string) bool {
+	now := time.Now().UTC().Truncate(time.Second)
+	claimed, err := jm.store.ClaimRun(name, now)
+	if err != nil {
+		log.Printf("[ERROR] Failed to claim run of job %s, running it anyway: %v", name, err)
+	} else if !claimed {
+		log.Printf("[JOB] Run of %s at %s was already claimed by another instance", name, now.Format(time.RFC3339))
+		return false
+	}
+
+	e.mu.Lock()
+	e.state.LastRunAt = &now
+	e.mu.Unlock()
+	return true
+}
+
+// markRun stores the current time as the start of the latest scheduled run of the job
+func (jm *JobManager) markRun(e *jobEntry, name string) {
+	now := time.Now().UTC().Truncate(time.Second)
+	e.mu.Lock()
+	e.state.LastRunAt = &now
+	e.mu.Unlock()
+
+	if err := jm.store.SetJobLastRun(name, now); err != nil {
+		log.Printf("[ERROR] Failed to store last run of job %s: %v", name, err)
+	}
+}
+
+// acquire claims the run slot of the entry. It returns false if the run has to be skipped
+func (e *jobEntry) acquire(policy ConcurrencyPolicy) bool {
+	if policy == ConcurrencyReplace {
+		// Runs started under the allow policy before an update hold no slot, so every
+		// run in progress is cancelled, not only the one holding the slot
+		e.cancelRuns()
+		e.slot <- struct{}{}
+		return true
+	}
+
+	select {
+	case e.slot <- struct{}{}:
+		return true
+	default:
+	}
+
+	switch policy {
+	case ConcurrencyQueue:
+		e.mu.Lock()
+		if e.queued {
+			e.mu.Unlock()
+			return false
+		}
+		e.queued = true
+		e.mu.Unlock()
+
+		e.slot <- struct{}{}
+		e.mu.Lock()
+		e.queued = false
+		e.mu.Unlock()
+		return true
+	default:
+		return false
+	}
+}
+
+// track records the cancel func of a run in progress. The returned func forgets it and
+// cancels the run's context once the run is over
+func (e *jobEntry) track(cancel context.CancelFunc) func() {
+	e.mu.Lock()
+	if e.cancels == nil {
+		e.cancels = make(map[uint64]context.CancelFunc)
+	}
+	e.lastRun++
+	run := e.lastRun
+	e.cancels[run] = cancel
+	e.mu.Unlock()
+
+	return func() {
+		e.mu.Lock()
+		delete(e.cancels, run)
+		e.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelRuns cancels every run of the entry in progress, including those started
+// alongside each other under the allow policy
+func (e *jobEntry) cancelRuns() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, cancel := range e.cancels {
+		cancel()
+	}
+}
+
+// release frees the run slot claimed by acquire
+func (e *jobEntry) release() {
+	<-e.slot
+}
+
+// skip records a run that was dropped by the concurrency policy
+func (jm *JobManager) skip(job Job, trigger Trigger) {
+	log.Printf("[WARN] Skipping run of job %s: previous run still in progress", job.Name)
+	metrics.JobSkippedRuns.WithLabelValues(job.Name).Inc()
+
+	reason := fmt.Sprintf("skipped by %s concurrency policy: previous run still in progress", job.Concurrency)
+	exec := &Execution{JobName: job.Name, Attempt: 1, Trigger: trigger}
+	if err := jm.recordExecution(exec, ExecutionSkipped, reason); err != nil {
+		log.Printf("[ERROR] Failed to record skipped run of job %s: %v", job.Name, err)
+	}
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import "time"
+
+// EventType names a change to a job or to one of its executions
+type EventType string
+
+const (
+	EventJobRegistered      EventType = "job.registered"
+	EventJobDeregistered    EventType = "job.deregistered"
+	EventJobUpdated         EventType = "job.updated"
+	EventExecutionStarted   EventType = "execution.started"
+	EventExecutionSucceeded EventType = "execution.succeeded"
+	EventExecutionFailed    EventType = "execution.failed"
+)
+
+// Event is a change to a job or to one of its executions. Job is set on the job events
+// but job.deregistered, and Execution on the execution events, which are sent for every
+// attempt of a run
+type Event struct {
+	Type      EventType
+	JobName   string
+	Job       *Job
+	Execution *Execution
+	Time      time.Time
+}
+
+// EventPublisher sends the events of the manager to other services. Publish is called
+// with the manager's lock held and from the goroutines running the jobs, so it must not block
+type EventPublisher interface {
+	Publish(Event)
+}
+
+// noEvents is the EventPublisher of a manager that publishes no events
+type noEvents struct{}
+
+func (noEvents) Publish(Event) {}
+
+// SetEventPublisher makes the manager publish its events through p. It must be called
+// before the jobs are loaded
+func (jm *JobManager) SetEventPublisher(p EventPublisher) {
+	jm.events = p
+}
+
+// publishJob publishes an event about the definition of a job, with its headers redacted
+func (jm *JobManager) publishJob(eventType EventType, job Job) {
+	job = job.Redacted()
+	jm.events.Publish(Event{Type: eventType, JobName: job.Name, Job: &job, Time: time.Now().UTC()})
+}
+
+// redactedHeader replaces the header values of a job sent to other services
+const redactedHeader = "[redacted]"
+
+// Redacted returns a copy of the job whose request and message headers have their values
+// replaced, as they often carry credentials. It is what gets sent to other services
+func (j Job) Redacted() Job {
+	j.Headers = redactHeaders(j.Headers)
+	if j.Kafka != nil {
+		target := *j.Kafka
+		target.Headers = redactHeaders(target.Headers)
+		j.Kafka = &target
+	}
+	return j
+}
+
+// redactHeaders returns a copy of headers with every value replaced by redactedHeader
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name := range headers {
+		redacted[name] = redactedHeader
+	}
+	return redacted
+}
+
+// publishExecution publishes an event about an execution, as it is when called
+func (jm *JobManager) publishExecution(eventType EventType, exec Execution) {
+	jm.events.Publish(Event{Type: eventType, JobName: exec.JobName, Execution: &exec, Time: time.Now().UTC()})
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"sync"
+	"testing"
+)
+
+// recordedEvents records the events published by a manager
+type recordedEvents struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recordedEvents) Publish(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// ofType returns the events of a type published so far
+func (r *recordedEvents) ofType(eventType EventType) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []Event
+	for _, event := range r.events {
+		if event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestRedacted(t *testing.T) {
+	tests := []struct {
+		name string
+		job  Job
+		want Job
+	}{
+		{
+			name: "request headers",
+			job:  Job{Name: "ping", Headers: map[string]string{"Authorization": "Bearer secret", "X-Trace": "on"}},
+			want: Job{Name: "ping", Headers: map[string]string{"Authorization": redactedHeader, "X-Trace": redactedHeader}},
+		},
+		{
+			name: "message headers",
+			job:  Job{Name: "ping", Kafka: &KafkaTarget{Topic: "pings", Headers: map[string]string{"token": "secret"}}},
+			want: Job{Name: "ping", Kafka: &KafkaTarget{Topic: "pings", Headers: map[string]string{"token": redactedHeader}}},
+		},
+		{name: "no headers", job: Job{Name: "ping", Kafka: &KafkaTarget{Topic: "pings"}}, want: Job{Name: "ping", Kafka: &KafkaTarget{Topic: "pings"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redacted := tt.job.Redacted()
+			if !equalHeaders(redacted.Headers, tt.want.Headers) {
+				t.Errorf("redacted headers are %v, want %v", redacted.Headers, tt.want.Headers)
+			}
+			if tt.want.Kafka != nil {
+				if redacted.Kafka == tt.job.Kafka {
+					t.Fatalf("redacted job shares the kafka target of the original")
+				}
+				if redacted.Kafka.Topic != tt.want.Kafka.Topic || !equalHeaders(redacted.Kafka.Headers, tt.want.Kafka.Headers) {
+					t.Errorf("redacted kafka target is %+v, want %+v", redacted.Kafka, tt.want.Kafka)
+				}
+			}
+
+			// The job itself keeps its values
+			for name, value := range tt.job.Headers {
+				if value == redactedHeader {
+					t.Errorf("original header %s was redacted", name)
+				}
+			}
+			if tt.job.Kafka != nil {
+				for name, value := range tt.job.Kafka.Headers {
+					if value == redactedHeader {
+						t.Errorf("original message header %s was redacted", name)
+					}
+				}
+			}
+		})
+	}
+}
+
+// equalHeaders reports whether two sets of headers are equal, telling nil from empty
+func equalHeaders(a, b map[string]string) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if other, ok := b[name]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestJobEventsAreRedacted(t *testing.T) {
+	jm, store := newTestManager(t)
+	events := &recordedEvents{}
+	jm.SetEventPublisher(events)
+
+	job := Job{Name: "ping", Cron: "0 0 1 1 *", Endpoint: "http://localhost:3000/ping", Headers: map[string]string{"Authorization": "Bearer secret"}}
+	if _, err := jm.Register(job); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := jm.Update(Job{Name: "ping", Headers: map[string]string{"Authorization": "Bearer rotated"}}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	for _, eventType := range []EventType{EventJobRegistered, EventJobUpdated} {
+		published := events.ofType(eventType)
+		if len(published) != 1 || published[0].Job == nil {
+			t.Fatalf("%d %s event(s) with a job, want 1", len(published), eventType)
+		}
+		if got := published[0].Job.Headers["Authorization"]; got != redactedHeader {
+			t.Errorf("%s event has Authorization %q, want it redacted", eventType, got)
+		}
+	}
+
+	// The manager still calls the endpoint with the real value
+	stored, err := jm.Get("ping")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := stored.Headers["Authorization"]; got != "Bearer rotated" {
+		t.Errorf("job has Authorization %q, want Bearer rotated", got)
+	}
+	if loaded, _ := store.LoadJobs(); len(loaded) != 1 || loaded[0].Job.Headers["Authorization"] != "Bearer rotated" {
+		t.Errorf("stored job lost its header value: %+v", loaded)
+	}
+}
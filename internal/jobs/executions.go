@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// maxResponseBodySize is the number of response bytes kept per execution
+	maxResponseBodySize = 4096
+
+	defaultExecutionLimit = 50
+	maxExecutionLimit     = 500
+)
+
+// startExecution stores exec, which identifies the job, attempt and trigger of a run, as running
+func (jm *JobManager) startExecution(exec *Execution) error {
+	exec.Status = ExecutionRunning
+	exec.StartedAt = time.Now().UTC()
+	return jm.store.StartExecution(exec)
+}
+
+// finishExecution stores the final state of an execution
+func (jm *JobManager) finishExecution(exec *Execution) error {
+	finished := time.Now().UTC()
+	exec.FinishedAt = &finished
+	exec.Duration = finished.Sub(exec.StartedAt).Seconds()
+
+	if exec.ID == 0 {
+		return fmt.Errorf("execution for job %q was never stored", exec.JobName)
+	}
+	return jm.store.FinishExecution(exec)
+}
+
+// recordExecution stores a run that finished without making a request, such as a skipped run
+func (jm *JobManager) recordExecution(exec *Execution, status ExecutionStatus, reason string) error {
+	if err := jm.startExecution(exec); err != nil {
+		return err
+	}
+	exec.Status = status
+	exec.Error = reason
+	return jm.finishExecution(exec)
+}
+
+// Executions returns a page of the execution history of a job, newest first,
+// along with the total number of executions matching the filter
+func (jm *JobManager) Executions(name string, filter ExecutionFilter) ([]Execution, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultExecutionLimit
+	}
+	if filter.Limit > maxExecutionLimit {
+		filter.Limit = maxExecutionLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return jm.store.ListExecutions(name, filter)
+}
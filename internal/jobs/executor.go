@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"schedulerservice/internal/metrics"
+)
+
+// TargetType is the kind of call a job makes on each run
+type TargetType string
+
+const (
+	// TargetHTTP makes the HTTP request described by the endpoint, method, headers, query and body of the job
+	TargetHTTP TargetType = "http"
+	// TargetKafka produces the message described by the Kafka settings of the job
+	TargetKafka TargetType = "kafka"
+)
+
+// Result is the outcome of the call made by an attempt of a run
+type Result struct {
+	// StatusCode is the status of an HTTP response, 0 for the other targets
+	StatusCode int
+	// Body is the response body of an HTTP call, or what the call did for the other targets
+	Body string
+	// Kind classifies the error of a failed call, for the retry policy
+	Kind ErrorKind
+}
+
+// Executor makes the calls of the jobs of one target type. The call is cancelled through
+// ctx, which also carries the timeout of the job
+type Executor interface {
+	Execute(ctx context.Context, job Job, exec Execution) (Result, error)
+}
+
+// SetExecutor makes the manager run the jobs of a target type through executor. The HTTP
+// executor is set by NewJobManager. It must be called before the jobs are loaded, and
+// executors implementing io.Closer are closed by ShutDown
+func (jm *JobManager) SetExecutor(target TargetType, executor Executor) {
+	jm.executors[target] = executor
+}
+
+// checkTarget fails if no executor runs the target of job
+func (jm *JobManager) checkTarget(job Job) error {
+	if _, ok := jm.executors[job.Target]; !ok {
+		return fmt.Errorf("target %q is not available on this service", job.Target)
+	}
+	return nil
+}
+
+// call makes the call of an attempt through the executor of the job's target
+func (jm *JobManager) call(ctx context.Context, job Job, exec Execution) (Result, error) {
+	executor, ok := jm.executors[job.Target]
+	if !ok {
+		return Result{Kind: ErrorRequest}, fmt.Errorf("target %q is not available on this service", job.Target)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, job.timeout())
+	defer cancel()
+	return executor.Execute(ctx, job, exec)
+}
+
+// closeExecutors closes the executors holding resources
+func (jm *JobManager) closeExecutors() {
+	for target, executor := range jm.executors {
+		if closer, ok := executor.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("[WARN] Failed to close the %s executor: %v", target, err)
+			}
+		}
+	}
+}
+
+// describeTarget describes the call made by the job, for the logs
+func (job Job) describeTarget() string {
+	if job.Target == TargetKafka {
+		return "kafka topic " + job.Kafka.Topic
+	}
+	return job.Method + " " + job.Endpoint
+}
+
+// httpExecutor makes the HTTP requests of the jobs with the http target. A response with
+// a non-2xx status fails the attempt
+type httpExecutor struct{}
+
+func (httpExecutor) Execute(ctx context.Context, job Job, exec Execution) (Result, error) {
+	statusCode, body, err := handleJobRequest(ctx, job)
+	result := Result{StatusCode: statusCode, Body: body}
+	label := strconv.Itoa(statusCode)
+	if err != nil {
+		result.Kind = classifyError(err, statusCode)
+		if statusCode == 0 {
+			label = string(result.Kind)
+		}
+	}
+	metrics.JobHTTPRequests.WithLabelValues(job.Name, label).Inc()
+	return result, err
+}
+
+// handleJobRequest makes the HTTP request for the job and returns the status code
+// and the response body truncated to maxResponseBodySize
+func handleJobRequest(ctx context.Context, job Job) (int, string, error) {
+	req, callErr := newJobRequest(ctx, job)
+	if callErr != nil {
+		return 0, "", callErr
+	}
+
+	resp, callErr := http.DefaultClient.Do(req)
+	if callErr != nil {
+		return 0, "", callErr
+	}
+	defer resp.Body.Close()
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if readErr != nil {
+		log.Printf("[WARN] Failed to read response body of job %s: %v", job.Name, readErr)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("[ERROR] Job %s returned non-2xx status: %d", job.Name, resp.StatusCode)
+		return resp.StatusCode, string(body), fmt.Errorf("non-2xx status: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, string(body), nil
+}
@@ -1,216 +1,728 @@
 package jobs
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
-	"sync"
+	"math"
+	"sort"
+	"strconv"
 	"time"
 
-	"schedulerservice/internal/auth"
-	"schedulerservice/internal/db"
 	"schedulerservice/internal/metrics"
 
 	"github.com/robfig/cron/v3"
 )
 
-var (
-	defaultManager *JobManager
-	managerOnce    sync.Once
-)
-
-// GetJobManager returns the singleton JobManager instance
-func GetJobManager() *JobManager {
-	managerOnce.Do(func() {
-		defaultManager = NewJobManager()
-		if err := defaultManager.LoadJobs(); err != nil {
-			log.Printf("[ERROR] Failed to load jobs: %v", err)
-		}
-	})
-	return defaultManager
-}
-
-// NewJobManager creates a new JobManager instance
-func NewJobManager() *JobManager {
-	db.InitDBConnection()
-	LoadMetricsFromDB()
-	c := cron.New()
-	c.Start()
+// NewJobManager creates a new JobManager keeping its jobs in store and restores the
+// metrics stored there. Jobs only run once StartScheduling is called
+func NewJobManager(store Store) *JobManager {
+	LoadMetricsFromDB(store)
+	runCtx, stopRuns := context.WithCancel(context.Background())
 	return &JobManager{
-		cron: c,
-		jobs: make(map[string]cron.EntryID),
+		store:    store,
+		cron:     cron.New(cron.WithParser(cronParser), cron.WithLocation(time.UTC)),
+		jobs:     make(map[string]*jobEntry),
+		runCtx:   runCtx,
+		stopRuns: stopRuns,
+		events:   noEvents{},
+		executors: map[TargetType]Executor{
+			TargetHTTP: httpExecutor{},
+		},
 	}
 }
 
-// LoadJobs loads jobs from the database and registers them
+// LoadJobs loads jobs from the store and schedules them
 func (jm *JobManager) LoadJobs() error {
-	rows, err := db.GetDB().Query("SELECT name, cron, endpoint FROM jobs")
+	stored, err := jm.readJobs()
+	if err != nil {
+		return err
+	}
+
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	jm.scheduleStored(stored)
+	return nil
+}
+
+// readJobs reads every valid job from the store
+func (jm *JobManager) readJobs() ([]StoredJob, error) {
+	stored, err := jm.store.LoadJobs()
 	if err != nil {
-		return fmt.Errorf("failed to load jobs: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var job Job
-		if err := rows.Scan(&job.Name, &job.Cron, &job.Endpoint); err != nil {
-			return fmt.Errorf("failed to scan job: %w", err)
+	valid := stored[:0]
+	for _, s := range stored {
+		if err := validateJob(&s.Job); err != nil {
+			log.Printf("[WARN] Failed to load job %s: %v", s.Job.Name, err)
+			continue
 		}
+		valid = append(valid, s)
+	}
+	return valid, nil
+}
 
-		if err := jm.Register(job); err != nil {
-			log.Printf("[WARN] Failed to register job %s: %v", job.Name, err)
+// scheduleStored schedules jobs read by readJobs. The caller must hold jm.mu
+func (jm *JobManager) scheduleStored(stored []StoredJob) {
+	for _, s := range stored {
+		if err := jm.schedule(s.Job); err != nil {
+			log.Printf("[WARN] Failed to schedule job %s: %v", s.Job.Name, err)
+			continue
 		}
+		entry := jm.jobs[s.Job.Name]
+		entry.state = s.State
+		entry.createdAt = s.CreatedAt
 	}
-	return nil
 }
 
-func LoadMetricsFromDB() {
-	rows, err := db.GetDB().Query("SELECT metric_name, metric_value FROM metrics")
+// LoadMetricsFromDB restores the metrics kept in store, and checks that every global
+// metric has a row there to be kept in
+func LoadMetricsFromDB(store Store) {
+	stored, err := store.LoadMetrics()
 	if err != nil {
 		log.Printf("[ERROR] Failed to load metrics from database: %v", err)
 		return
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var metricName string
-		var metricValue float64
-		if err := rows.Scan(&metricName, &metricValue); err != nil {
-			log.Printf("[ERROR] Failed to scan metric: %v", err)
+	found := make(map[metrics.MetricName]bool, len(stored))
+	for _, metric := range stored {
+		if metric.JobName == "" {
+			found[metric.Name] = true
+		}
+	}
+	for _, name := range GlobalMetrics {
+		if !found[name] {
+			log.Printf("[ERROR] Global metric %s has no row in the database: its value will not be persisted until the database is seeded", name)
+		}
+	}
+
+	durations := make(jobDurations)
+	for _, metric := range stored {
+		if metric.JobName == "" {
+			restoreGlobalMetric(metric)
 			continue
 		}
 
-		switch metricName {
-		case string(metrics.TotalJobs):
-			metrics.JobsRegisteredTotal.Add(metricValue)
-		case string(metrics.ActiveJobs):
-			metrics.JobsActive.Set(metricValue)
-		case string(metrics.TotalExecutions):
-			// Assuming you have job-specific labels, you may need to handle this differently
-		case string(metrics.TotalFailures):
-			// Handle job-specific labels
-		case string(metrics.ExecutionDuration):
-			// Handle histogram buckets
+		switch metric.Name {
+		case metrics.TotalExecutions:
+			metrics.JobExecutions.WithLabelValues(metric.JobName).Add(metric.Value)
+		case metrics.TotalFailures:
+			metrics.JobFailures.WithLabelValues(metric.JobName).Add(metric.Value)
+		case metrics.ExecutionDurationSum:
+			durations.of(metric.JobName).sum = metric.Value
+		case metrics.ExecutionDurationCount:
+			durations.of(metric.JobName).count = uint64(math.Round(metric.Value))
 		default:
-			log.Printf("[WARN] Unknown metric %s", metricName)
+			if bound, ok := metrics.ParseDurationBucket(metric.Name); ok {
+				durations.of(metric.JobName).buckets[bound] = uint64(math.Round(metric.Value))
+				continue
+			}
+			log.Printf("[WARN] Unknown metric %s for job %s", metric.Name, metric.JobName)
 		}
 	}
+
+	for jobName, totals := range durations {
+		totals.restore(jobName)
+	}
 }
 
-// Register adds a new job to the manager
-func (jm *JobManager) Register(job Job) error {
+// restoreGlobalMetric restores a service-wide metric. The global execution, failure and
+// duration totals are only kept in the database, as Prometheus has them per job
+func restoreGlobalMetric(metric Metric) {
+	switch metric.Name {
+	case metrics.TotalJobs:
+		metrics.JobsRegisteredTotal.Add(metric.Value)
+	case metrics.ActiveJobs:
+		metrics.JobsActive.Set(metric.Value)
+	case metrics.TotalExecutions, metrics.TotalFailures, metrics.ExecutionDuration:
+	default:
+		log.Printf("[WARN] Unknown metric %s", metric.Name)
+	}
+}
+
+// durationTotals are the stored sum, count and bucket counts of the execution durations of a job
+type durationTotals struct {
+	sum     float64
+	count   uint64
+	buckets map[float64]uint64
+}
+
+// jobDurations are the stored duration totals, by job name
+type jobDurations map[string]*durationTotals
+
+// of returns the totals of a job, adding them if needed
+func (d jobDurations) of(jobName string) *durationTotals {
+	totals, exists := d[jobName]
+	if !exists {
+		totals = &durationTotals{buckets: make(map[float64]uint64)}
+		d[jobName] = totals
+	}
+	return totals
+}
+
+// restore sets the totals as the starting point of the duration histogram of the job.
+// Databases written before the buckets were stored only have the sum and count, so
+// their runs are put in the bucket of the mean duration
+func (d *durationTotals) restore(jobName string) {
+	if d.count == 0 {
+		return
+	}
+	if len(d.buckets) == 0 {
+		if i := sort.SearchFloat64s(metrics.DurationBuckets, d.sum/float64(d.count)); i < len(metrics.DurationBuckets) {
+			d.buckets[metrics.DurationBuckets[i]] = d.count
+		}
+	}
+	metrics.RestoreJobDuration(jobName, d.count, d.sum, d.buckets)
+}
+
+// addGlobalMetric adds value to a service-wide metric kept in the store
+func (jm *JobManager) addGlobalMetric(name metrics.MetricName, value float64) {
+	if err := jm.store.AddGlobalMetric(name, value); err != nil {
+		log.Printf("[ERROR] Failed to update metric %s in database: %v", name, err)
+	}
+}
+
+// addJobMetric adds value to a metric of a single job kept in the store
+func (jm *JobManager) addJobMetric(name metrics.MetricName, jobName string, value float64) {
+	if err := jm.store.AddJobMetric(name, jobName, value); err != nil {
+		log.Printf("[ERROR] Failed to update metric %s of job %s in database: %v", name, jobName, err)
+	}
+}
+
+// Register adds a new job to the manager and returns it as stored, with its defaults
+// filled in and a delay turned into run_at
+func (jm *JobManager) Register(job Job) (Job, error) {
 	jm.mu.Lock()
 	defer jm.mu.Unlock()
 
+	if err := jm.refuseWhileDraining("register", job.Name); err != nil {
+		return Job{}, err
+	}
+
 	if _, exists := jm.jobs[job.Name]; exists {
-		return fmt.Errorf("job %q already exists", job.Name)
+		return Job{}, fmt.Errorf("job %q %w", job.Name, ErrJobExists)
 	}
 
-	id, dbErr := jm.cron.AddFunc(job.Cron, func() {
-		start := time.Now()
-		log.Printf("[JOB] Executing %s -> %s", job.Name, job.Endpoint)
-		if err := handleJobRequest(job); err != nil {
-			log.Printf("[ERROR] Failed to execute job %s: %v", job.Name, err)
-			metrics.JobFailures.WithLabelValues(job.Name).Inc()
-			db.UpdateMetric(metrics.TotalFailures, 1, job.Name)
-			return
-		}
-		duration := time.Since(start).Seconds()
-		metrics.JobExecutions.WithLabelValues(job.Name).Inc()
-		metrics.JobDuration.WithLabelValues(job.Name).Observe(duration)
-		db.UpdateGlobalMetric(metrics.TotalExecutions, 1)
-		db.UpdateGlobalMetric(metrics.ExecutionDuration, duration)
-	})
+	job.CompletedAt = nil
+	if err := validateJob(&job); err != nil {
+		return Job{}, invalidJob(err)
+	}
+	if err := jm.checkTarget(job); err != nil {
+		return Job{}, invalidJob(err)
+	}
+	if job.Type == JobTypeOnce && !job.RunAt.After(time.Now()) {
+		return Job{}, invalidJob(fmt.Errorf("run_at must be in the future"))
+	}
 
-	if dbErr != nil {
-		return fmt.Errorf("invalid cron: %w", dbErr)
+	if err := jm.schedule(job); err != nil {
+		return Job{}, err
 	}
+	jm.jobs[job.Name].createdAt = time.Now()
 
-	_, dbErr = db.GetDB().Exec(
-		"INSERT INTO jobs (name, cron, endpoint) VALUES (?, ?, ?)",
-		job.Name, job.Cron, job.Endpoint,
-	)
-	if dbErr != nil {
-		jm.cron.Remove(id)
-		return fmt.Errorf("failed to save job in database: %w", dbErr)
+	if dbErr := jm.store.InsertJob(job); dbErr != nil {
+		jm.cron.Remove(jm.jobs[job.Name].id)
+		delete(jm.jobs, job.Name)
+		return Job{}, fmt.Errorf("failed to save job in database: %w", dbErr)
 	}
 
-	jm.jobs[job.Name] = id
 	metrics.JobsRegisteredTotal.Inc()
 	metrics.JobsActive.Inc()
-	db.UpdateGlobalMetric(metrics.TotalJobs, 1)
-	db.UpdateGlobalMetric(metrics.ActiveJobs, 1)
-	log.Printf("[JOB] Registered %s (%s)", job.Name, job.Cron)
-	return nil
+	jm.addGlobalMetric(metrics.TotalJobs, 1)
+	jm.addGlobalMetric(metrics.ActiveJobs, 1)
+	log.Printf("[JOB] Registered %s (%s)", job.Name, job.describeSchedule())
+	jm.publishJob(EventJobRegistered, job)
+	return job, nil
 }
 
-// handleJobRequest makes the HTTP request for the job
-func handleJobRequest(job Job) error {
-	req, callErr := http.NewRequest(http.MethodGet, job.Endpoint, nil)
-	if callErr != nil {
-		return callErr
+// schedule adds the job to the manager and, unless it is paused, to the cron scheduler.
+// The caller must hold jm.mu
+func (jm *JobManager) schedule(job Job) error {
+	if _, exists := jm.jobs[job.Name]; exists {
+		return fmt.Errorf("job %q %w", job.Name, ErrJobExists)
 	}
-	auth.AddAPIKeyToRequest(req)
 
-	client := &http.Client{}
-	resp, callErr := client.Do(req)
-	if callErr != nil {
-		return callErr
+	entry := &jobEntry{job: job, slot: make(chan struct{}, 1)}
+	if !job.Paused {
+		if err := jm.activate(entry); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
+	jm.jobs[job.Name] = entry
+	return nil
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Printf("[ERROR] Job %s returned non-2xx status: %d", job.Name, resp.StatusCode)
-		metrics.JobFailures.WithLabelValues(job.Name).Inc()
-		db.UpdateMetric(metrics.TotalFailures, 1, job.Name)
-		return callErr
+// activate adds the cron entry of a job. A one-shot job whose time has already
+// passed is run right away instead, if this instance is scheduling. The caller must hold jm.mu
+func (jm *JobManager) activate(entry *jobEntry) error {
+	job := entry.job
+	if job.CompletedAt != nil {
+		return nil
+	}
+	if job.Type == JobTypeOnce && !job.RunAt.After(time.Now()) {
+		// An instance that is not scheduling leaves it to the leader, which runs it on election
+		if jm.scheduling {
+			log.Printf("[JOB] One-shot job %s missed its run at %s, running it now", job.Name, job.RunAt.Format(time.RFC3339))
+			go jm.run(entry, TriggerScheduled)
+		}
+		return nil
 	}
 
+	id, err := jm.addCronEntry(entry, job)
+	if err != nil {
+		return err
+	}
+	entry.id = id
 	return nil
 }
 
+// addCronEntry adds a cron entry that runs the entry with the schedule of job
+func (jm *JobManager) addCronEntry(entry *jobEntry, job Job) (cron.EntryID, error) {
+	fire := func() {
+		jm.run(entry, TriggerScheduled)
+	}
+	if job.Type == JobTypeOnce {
+		return jm.cron.Schedule(onceSchedule{at: *job.RunAt}, cron.FuncJob(fire)), nil
+	}
+
+	id, err := jm.cron.AddFunc(job.spec(), fire)
+	if err != nil {
+		return 0, invalidJob(fmt.Errorf("invalid cron: %w", err))
+	}
+	return id, nil
+}
+
+// execute runs the job, retrying failed attempts according to its retry policy,
+// records every attempt in the execution history and updates the metrics.
+// Cancelling ctx aborts the run. It returns the final status and duration of the run
+func (jm *JobManager) execute(ctx context.Context, job Job, trigger Trigger) (ExecutionStatus, float64) {
+	start := time.Now()
+	maxAttempts := job.Retry.maxAttempts()
+
+	for attempt := 1; ; attempt++ {
+		exec, kind, callErr := jm.attempt(ctx, job, attempt, trigger)
+		duration := time.Since(start).Seconds()
+		if exec.Status == ExecutionCancelled {
+			log.Printf("[WARN] Run of job %s was cancelled during attempt %d", job.Name, attempt)
+			metrics.JobCancelledRuns.WithLabelValues(job.Name).Inc()
+			return ExecutionCancelled, duration
+		}
+		if callErr == nil {
+			metrics.JobExecutions.WithLabelValues(job.Name).Inc()
+			metrics.JobDuration.WithLabelValues(job.Name).Observe(duration)
+			jm.addGlobalMetric(metrics.TotalExecutions, 1)
+			jm.addGlobalMetric(metrics.ExecutionDuration, duration)
+			jm.addJobMetric(metrics.TotalExecutions, job.Name, 1)
+			jm.addJobMetric(metrics.ExecutionDurationSum, job.Name, duration)
+			jm.addJobMetric(metrics.ExecutionDurationCount, job.Name, 1)
+			if bucket, ok := metrics.DurationBucket(duration); ok {
+				jm.addJobMetric(bucket, job.Name, 1)
+			}
+			return ExecutionSucceeded, duration
+		}
+
+		if attempt >= maxAttempts || !job.Retry.shouldRetry(exec.StatusCode, kind) {
+			log.Printf("[ERROR] Failed to execute job %s after %d attempt(s): %v", job.Name, attempt, callErr)
+			metrics.JobFailures.WithLabelValues(job.Name).Inc()
+			jm.addJobMetric(metrics.TotalFailures, job.Name, 1)
+			return ExecutionFailed, duration
+		}
+
+		delay := job.Retry.delay(attempt)
+		log.Printf("[WARN] Attempt %d/%d of job %s failed (%s): %v, retrying in %s",
+			attempt, maxAttempts, job.Name, kind, callErr, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			log.Printf("[WARN] Run of job %s was cancelled before attempt %d", job.Name, attempt+1)
+			metrics.JobCancelledRuns.WithLabelValues(job.Name).Inc()
+			exec := &Execution{JobName: job.Name, Attempt: attempt + 1, Trigger: trigger}
+			if err := jm.recordExecution(exec, ExecutionCancelled, "run cancelled before the attempt started"); err != nil {
+				log.Printf("[ERROR] Failed to record cancelled run of job %s: %v", job.Name, err)
+			}
+			return ExecutionCancelled, time.Since(start).Seconds()
+		}
+	}
+}
+
+// attempt makes a single request for the job and records it in the execution history
+func (jm *JobManager) attempt(ctx context.Context, job Job, attempt int, trigger Trigger) (*Execution, ErrorKind, error) {
+	exec := &Execution{JobName: job.Name, Attempt: attempt, Trigger: trigger}
+	if err := jm.startExecution(exec); err != nil {
+		log.Printf("[ERROR] Failed to record execution of job %s: %v", job.Name, err)
+	}
+	jm.publishExecution(EventExecutionStarted, *exec)
+
+	log.Printf("[JOB] Executing %s -> %s (%s, attempt %d)", job.Name, job.describeTarget(), trigger, attempt)
+	result, callErr := jm.call(ctx, job, *exec)
+	exec.StatusCode = result.StatusCode
+	exec.ResponseBody = result.Body
+	exec.Status = ExecutionSucceeded
+	var kind ErrorKind
+	switch {
+	case callErr != nil && ctx.Err() != nil:
+		exec.Status = ExecutionCancelled
+		exec.Error = callErr.Error()
+	case callErr != nil:
+		kind = result.Kind
+		exec.Status = ExecutionFailed
+		exec.Error = callErr.Error()
+	}
+	if err := jm.finishExecution(exec); err != nil {
+		log.Printf("[ERROR] Failed to record execution of job %s: %v", job.Name, err)
+	}
+	switch exec.Status {
+	case ExecutionSucceeded:
+		jm.publishExecution(EventExecutionSucceeded, *exec)
+	case ExecutionFailed:
+		jm.publishExecution(EventExecutionFailed, *exec)
+	}
+
+	metrics.JobAttempts.WithLabelValues(job.Name, strconv.Itoa(attempt), string(exec.Status), string(trigger)).Inc()
+	return exec, kind, callErr
+}
+
 // Deregister removes a job from the manager
 func (jm *JobManager) Deregister(name string) error {
 	jm.mu.Lock()
 	defer jm.mu.Unlock()
 
-	id, exists := jm.jobs[name]
+	if err := jm.refuseWhileDraining("deregister", name); err != nil {
+		return err
+	}
+
+	entry, exists := jm.jobs[name]
 	if !exists {
-		return fmt.Errorf("job %q does not exist", name)
+		return fmt.Errorf("job %q %w", name, ErrJobNotFound)
 	}
 
-	_, dbErr := db.GetDB().Exec("DELETE FROM jobs WHERE name = ?", name)
-	if dbErr != nil {
+	if dbErr := jm.store.DeleteJob(name); dbErr != nil {
 		return fmt.Errorf("failed to delete job from database: %w", dbErr)
 	}
-	db.UpdateGlobalMetric(metrics.ActiveJobs, -1)
-
-	jm.cron.Remove(id)
+	jm.cron.Remove(entry.id)
 	delete(jm.jobs, name)
-	metrics.JobsActive.Dec()
+	if entry.job.CompletedAt == nil {
+		metrics.JobsActive.Dec()
+		jm.addGlobalMetric(metrics.ActiveJobs, -1)
+	}
 	log.Printf("[JOB] Deregistered %s", name)
+	jm.events.Publish(Event{Type: EventJobDeregistered, JobName: name, Time: time.Now().UTC()})
 	return nil
 }
 
-// List returns a list of all registered jobs
+// Update changes the definition of a registered job in place. Only the non-zero fields
+// of job are applied, so a partial update such as a new cron expression keeps the
+// rest of the definition. The paused state is changed through Pause and Resume
+func (jm *JobManager) Update(job Job) (Job, error) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if err := jm.refuseWhileDraining("update", job.Name); err != nil {
+		return Job{}, err
+	}
+
+	entry, exists := jm.jobs[job.Name]
+	if !exists {
+		return job, fmt.Errorf("job %q %w", job.Name, ErrJobNotFound)
+	}
+
+	if entry.job.CompletedAt != nil {
+		return entry.job, invalidJob(fmt.Errorf("job %q has already completed", job.Name))
+	}
+
+	updated := mergeJob(entry.job, job)
+	if err := validateJob(&updated); err != nil {
+		return updated, invalidJob(err)
+	}
+	if err := jm.checkTarget(updated); err != nil {
+		return updated, invalidJob(err)
+	}
+	if updated.Type == JobTypeOnce && (job.RunAt != nil || job.Delay != 0) && !updated.RunAt.After(time.Now()) {
+		return updated, invalidJob(fmt.Errorf("run_at must be in the future"))
+	}
+
+	// The definition is swapped before the new cron entry is added, so every run it fires
+	// uses the new definition
+	previous, oldID := entry.job, entry.id
+	entry.setJob(updated)
+	if !updated.Paused && (updated.Type != JobTypeOnce || updated.RunAt.After(time.Now())) {
+		id, err := jm.addCronEntry(entry, updated)
+		if err != nil {
+			entry.setJob(previous)
+			return updated, err
+		}
+		entry.id = id
+	}
+
+	if err := jm.store.UpdateJob(updated); err != nil {
+		if entry.id != oldID {
+			jm.cron.Remove(entry.id)
+			entry.id = oldID
+		}
+		entry.setJob(previous)
+		return updated, fmt.Errorf("failed to update job in database: %w", err)
+	}
+
+	if entry.id != oldID {
+		jm.cron.Remove(oldID)
+	}
+	log.Printf("[JOB] Updated %s (%s)", updated.Name, updated.describeSchedule())
+	jm.publishJob(EventJobUpdated, updated)
+	return updated, nil
+}
+
+// setJob replaces the definition of the entry's job
+func (e *jobEntry) setJob(job Job) {
+	e.mu.Lock()
+	e.job = job
+	e.mu.Unlock()
+}
+
+// DecodeUpdate decodes a partial job definition for Update. A missing field keeps its
+// current value, while an explicit null clears headers, query and body
+func DecodeUpdate(data []byte) (Job, error) {
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return job, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return job, err
+	}
+	if isNull(fields["headers"]) {
+		job.Headers = map[string]string{}
+	}
+	if isNull(fields["query"]) {
+		job.Query = map[string]string{}
+	}
+	if isNull(fields["body"]) {
+		job.Body = json.RawMessage("null")
+	}
+	return job, nil
+}
+
+// isNull reports whether a field is present and set to null
+func isNull(field json.RawMessage) bool {
+	return field != nil && bytes.Equal(bytes.TrimSpace(field), []byte("null"))
+}
+
+// mergeJob applies the non-zero fields of update on top of current
+func mergeJob(current, update Job) Job {
+	merged := current
+	// A new cron expression or run time also switches the job between recurring and one-shot
+	switch {
+	case update.Cron != "":
+		merged.Type, merged.Cron, merged.RunAt = JobTypeCron, update.Cron, nil
+		if zone, _ := splitCronZone(update.Cron); zone != "" {
+			merged.Timezone = ""
+		}
+	case update.RunAt != nil || update.Delay != 0:
+		merged.Type, merged.Cron, merged.RunAt, merged.Delay = JobTypeOnce, "", update.RunAt, update.Delay
+	}
+	if update.Type != "" {
+		merged.Type = update.Type
+	}
+	if update.Timezone != "" {
+		merged.Timezone = update.Timezone
+		// A new timezone replaces the one set by a CRON_TZ= or TZ= prefix of the kept cron
+		if update.Cron == "" {
+			if zone, expr := splitCronZone(merged.Cron); zone != "" {
+				merged.Cron = expr
+			}
+		}
+	}
+	if update.Endpoint != "" {
+		merged.Endpoint = update.Endpoint
+	}
+	if update.Method != "" {
+		merged.Method = update.Method
+	}
+	if update.Headers != nil {
+		merged.Headers = update.Headers
+	}
+	if update.Query != nil {
+		merged.Query = update.Query
+	}
+	if update.Body != nil {
+		merged.Body = update.Body
+		if isNull(update.Body) {
+			merged.Body = nil
+		}
+	}
+	if update.Timeout != 0 {
+		merged.Timeout = update.Timeout
+	}
+	// Switching targets drops the settings of the previous one
+	if update.Target != "" && update.Target != merged.Target {
+		merged.Target = update.Target
+		if update.Target == TargetKafka {
+			merged.Endpoint, merged.Method = update.Endpoint, update.Method
+			merged.Headers, merged.Query, merged.Body = update.Headers, update.Query, update.Body
+		} else {
+			merged.Kafka = nil
+		}
+	}
+	if update.Kafka != nil {
+		merged.Kafka = update.Kafka
+	}
+	if update.Retry != nil {
+		merged.Retry = update.Retry
+	}
+	if update.Concurrency != "" {
+		merged.Concurrency = update.Concurrency
+	}
+	if update.Misfire != "" {
+		merged.Misfire = update.Misfire
+	}
+	if update.MisfireLimit != 0 {
+		merged.MisfireLimit = update.MisfireLimit
+	}
+	return merged
+}
+
+// Trigger starts a run of a job right away, outside of its schedule. The run goes
+// through the same concurrency, retry and recording steps as a scheduled one
+func (jm *JobManager) Trigger(name string) error {
+	jm.mu.Lock()
+	entry, exists := jm.jobs[name]
+	jm.mu.Unlock()
+	if !exists {
+		return fmt.Errorf("job %q %w", name, ErrJobNotFound)
+	}
+
+	if !jm.beginRun() {
+		return fmt.Errorf("cannot run job %q: %w", name, ErrShuttingDown)
+	}
+	log.Printf("[JOB] Triggered %s manually", name)
+	go jm.runCounted(entry, TriggerManual)
+	return nil
+}
+
+// Pause stops scheduling a job while keeping its definition. Runs in progress are left to finish
+func (jm *JobManager) Pause(name string) error {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if err := jm.refuseWhileDraining("pause", name); err != nil {
+		return err
+	}
+
+	entry, exists := jm.jobs[name]
+	if !exists {
+		return fmt.Errorf("job %q %w", name, ErrJobNotFound)
+	}
+	if entry.job.Paused {
+		return nil
+	}
+
+	if err := jm.store.SetJobPaused(name, true); err != nil {
+		return fmt.Errorf("failed to pause job in database: %w", err)
+	}
+
+	jm.cron.Remove(entry.id)
+	entry.mu.Lock()
+	entry.id = 0
+	entry.job.Paused = true
+	entry.mu.Unlock()
+	log.Printf("[JOB] Paused %s", name)
+	return nil
+}
+
+// Resume schedules a paused job again
+func (jm *JobManager) Resume(name string) error {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if err := jm.refuseWhileDraining("resume", name); err != nil {
+		return err
+	}
+
+	entry, exists := jm.jobs[name]
+	if !exists {
+		return fmt.Errorf("job %q %w", name, ErrJobNotFound)
+	}
+	if !entry.job.Paused {
+		return nil
+	}
+
+	if err := jm.activate(entry); err != nil {
+		return err
+	}
+	if err := jm.store.SetJobPaused(name, false); err != nil {
+		jm.cron.Remove(entry.id)
+		entry.id = 0
+		return fmt.Errorf("failed to resume job in database: %w", err)
+	}
+	// Runs skipped while paused are not misfires, so they are counted from now on
+	jm.markRun(entry, name)
+
+	entry.mu.Lock()
+	entry.job.Paused = false
+	entry.mu.Unlock()
+	log.Printf("[JOB] Resumed %s", name)
+	return nil
+}
+
+// List returns the definition and runtime state of all registered jobs, sorted by name
 func (jm *JobManager) List() []JobListItem {
 	jm.mu.Lock()
 	defer jm.mu.Unlock()
 
 	jobs := make([]JobListItem, 0, len(jm.jobs))
-	for name, id := range jm.jobs {
-		jobs = append(jobs, JobListItem{
-			Name:     name,
-			Cron:     jm.cron.Entry(id).Schedule,
-			Endpoint: jm.cron.Entry(id).Job.(cron.FuncJob),
-		})
+	for _, entry := range jm.jobs {
+		jobs = append(jobs, jm.describe(entry))
 	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
 	return jobs
 }
 
-// ShutDown stops the cron scheduler
-func (jm *JobManager) ShutDown() error {
-	if defaultManager != nil {
-		jm.cron.Stop()
+// Get returns the definition and runtime state of a single job
+func (jm *JobManager) Get(name string) (JobListItem, error) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	entry, exists := jm.jobs[name]
+	if !exists {
+		return JobListItem{}, fmt.Errorf("job %q %w", name, ErrJobNotFound)
 	}
-	return nil
+	return jm.describe(entry), nil
+}
+
+// describe builds the list item of a job. The caller must hold jm.mu
+func (jm *JobManager) describe(entry *jobEntry) JobListItem {
+	entry.mu.Lock()
+	item := JobListItem{
+		Job:           entry.job,
+		Paused:        entry.job.Paused,
+		Format:        entry.job.format(),
+		LastStatus:    entry.state.LastStatus,
+		LastDuration:  entry.state.LastDuration,
+		FailureStreak: entry.state.FailureStreak,
+	}
+	lastRunAt := entry.state.LastRunAt
+	entry.mu.Unlock()
+
+	if entry.id != 0 {
+		cronEntry := jm.cron.Entry(entry.id)
+		if !cronEntry.Next.IsZero() {
+			next := cronEntry.Next.UTC()
+			nextLocal := cronEntry.Next.In(item.location())
+			item.NextRun = &next
+			item.NextRunLocal = &nextLocal
+		}
+		if !cronEntry.Prev.IsZero() {
+			prev := cronEntry.Prev.UTC()
+			item.PrevRun = &prev
+		}
+	}
+	// The cron scheduler only knows about runs since the service started
+	if item.PrevRun == nil && lastRunAt != nil {
+		prev := lastRunAt.UTC()
+		item.PrevRun = &prev
+	}
+	return item
 }
@@ -0,0 +1,679 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"schedulerservice/internal/metrics"
+)
+
+// newTestManager creates a manager on a MemoryStore, shut down at the end of the test
+func newTestManager(t *testing.T) (*JobManager, *MemoryStore) {
+	t.Helper()
+	store := NewMemoryStore()
+	jm := NewJobManager(store)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		jm.ShutDown(ctx)
+	})
+	return jm, store
+}
+
+// newEndpoint serves handler and returns its URL
+func newEndpoint(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// okEndpoint answers 200 to every call and counts them
+func okEndpoint(t *testing.T, calls *atomic.Int32) string {
+	return newEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	})
+}
+
+// blockingEndpoint answers once release is closed, or gives up when the call is cancelled.
+// started receives a value as each call comes in
+func blockingEndpoint(t *testing.T, started chan<- struct{}, release <-chan struct{}) string {
+	return newEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// waitFor fails the test if cond is not met within a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// countExecutions returns the number of executions of a job matching filter
+func countExecutions(t *testing.T, jm *JobManager, name string, filter ExecutionFilter) int {
+	t.Helper()
+	_, total, err := jm.Executions(name, filter)
+	if err != nil {
+		t.Fatalf("Executions(%s): %v", name, err)
+	}
+	return total
+}
+
+func statusFilter(statuses ...ExecutionStatus) ExecutionFilter {
+	return ExecutionFilter{Statuses: statuses}
+}
+
+func TestRegister(t *testing.T) {
+	jm, store := newTestManager(t)
+	endpoint := newEndpoint(t, func(http.ResponseWriter, *http.Request) {})
+	jm.StartScheduling()
+
+	registered, err := jm.Register(Job{Name: "nightly", Cron: "0 3 * * *", Endpoint: endpoint})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if registered.Method != http.MethodGet || registered.Target != TargetHTTP || registered.Concurrency != ConcurrencyAllow {
+		t.Errorf("Register returned %+v, want the defaults filled in", registered)
+	}
+
+	stored, _ := store.LoadJobs()
+	if len(stored) != 1 || stored[0].Job.Name != "nightly" {
+		t.Fatalf("store holds %+v, want the nightly job", stored)
+	}
+	item, err := jm.Get("nightly")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if item.NextRun == nil {
+		t.Errorf("Get returned no next run for a scheduled job")
+	}
+
+	if _, err := jm.Register(Job{Name: "nightly", Cron: "0 4 * * *", Endpoint: endpoint}); !errors.Is(err, ErrJobExists) {
+		t.Errorf("Register of a duplicate returned %v, want ErrJobExists", err)
+	}
+	if _, err := jm.Register(Job{Name: "broken", Cron: "not a cron", Endpoint: endpoint}); !errors.Is(err, ErrInvalidJob) {
+		t.Errorf("Register of an invalid cron returned %v, want ErrInvalidJob", err)
+	}
+}
+
+func TestRegisterOnceReturnsRunAt(t *testing.T) {
+	jm, _ := newTestManager(t)
+	endpoint := newEndpoint(t, func(http.ResponseWriter, *http.Request) {})
+
+	before := time.Now()
+	registered, err := jm.Register(Job{Name: "reminder", Type: JobTypeOnce, Delay: Duration(time.Hour), Endpoint: endpoint})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if registered.RunAt == nil || registered.Delay != 0 {
+		t.Fatalf("Register returned run_at %v and delay %v, want the delay turned into run_at", registered.RunAt, registered.Delay)
+	}
+	if at := registered.RunAt.Sub(before); at < 59*time.Minute || at > time.Hour {
+		t.Errorf("run_at is %s after registration, want about an hour", at)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	jm, store := newTestManager(t)
+	endpoint := newEndpoint(t, func(http.ResponseWriter, *http.Request) {})
+
+	_, err := jm.Register(Job{
+		Name:     "report",
+		Cron:     "CRON_TZ=Europe/Paris 0 9 * * *",
+		Endpoint: endpoint,
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+		Body:     []byte(`{"full":true}`),
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	updated, err := jm.Update(Job{Name: "report", Method: http.MethodPost})
+	if err != nil {
+		t.Fatalf("Update of the method: %v", err)
+	}
+	if updated.Method != http.MethodPost || updated.Endpoint != endpoint || updated.Headers["Authorization"] == "" {
+		t.Errorf("Update returned %+v, want only the method changed", updated)
+	}
+
+	updated, err = jm.Update(Job{Name: "report", Timezone: "America/New_York"})
+	if err != nil {
+		t.Fatalf("Update of the timezone of a CRON_TZ job: %v", err)
+	}
+	if updated.Cron != "0 9 * * *" || updated.Timezone != "America/New_York" {
+		t.Errorf("Update returned cron %q and timezone %q, want the prefix replaced by the timezone", updated.Cron, updated.Timezone)
+	}
+
+	clear, err := DecodeUpdate([]byte(`{"name":"report","headers":null,"body":null}`))
+	if err != nil {
+		t.Fatalf("DecodeUpdate: %v", err)
+	}
+	updated, err = jm.Update(clear)
+	if err != nil {
+		t.Fatalf("Update clearing headers and body: %v", err)
+	}
+	if len(updated.Headers) != 0 || len(updated.Body) != 0 {
+		t.Errorf("Update returned headers %v and body %s, want both cleared", updated.Headers, updated.Body)
+	}
+
+	stored, _ := store.LoadJobs()
+	if stored[0].Job.Method != http.MethodPost || len(stored[0].Job.Headers) != 0 {
+		t.Errorf("store holds %+v, want the updated definition", stored[0].Job)
+	}
+
+	if _, err := jm.Update(Job{Name: "missing", Method: http.MethodPost}); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Update of an unknown job returned %v, want ErrJobNotFound", err)
+	}
+	if _, err := jm.Update(Job{Name: "report", Cron: "not a cron"}); !errors.Is(err, ErrInvalidJob) {
+		t.Errorf("Update to an invalid cron returned %v, want ErrInvalidJob", err)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	jm, store := newTestManager(t)
+	endpoint := newEndpoint(t, func(http.ResponseWriter, *http.Request) {})
+	jm.StartScheduling()
+
+	if _, err := jm.Register(Job{Name: "sync", Cron: "*/5 * * * *", Endpoint: endpoint}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if err := jm.Pause("sync"); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	item, _ := jm.Get("sync")
+	if !item.Paused || item.NextRun != nil {
+		t.Errorf("paused job has paused=%v and next run %v, want no next run", item.Paused, item.NextRun)
+	}
+	if stored, _ := store.LoadJobs(); !stored[0].Job.Paused {
+		t.Errorf("store does not hold the job as paused")
+	}
+
+	if err := jm.Resume("sync"); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	item, _ = jm.Get("sync")
+	if item.Paused || item.NextRun == nil {
+		t.Errorf("resumed job has paused=%v and next run %v, want it scheduled", item.Paused, item.NextRun)
+	}
+
+	if err := jm.Pause("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Pause of an unknown job returned %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	jm, _ := newTestManager(t)
+	var calls atomic.Int32
+	endpoint := okEndpoint(t, &calls)
+
+	if _, err := jm.Register(Job{Name: "ping", Cron: "0 0 1 1 *", Endpoint: endpoint}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := jm.Trigger("ping"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	waitFor(t, "the manual run to succeed", func() bool {
+		return countExecutions(t, jm, "ping", ExecutionFilter{Trigger: TriggerManual, Statuses: []ExecutionStatus{ExecutionSucceeded}}) == 1
+	})
+	if calls.Load() != 1 {
+		t.Errorf("endpoint was called %d times, want 1", calls.Load())
+	}
+
+	if err := jm.Trigger("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Trigger of an unknown job returned %v, want ErrJobNotFound", err)
+	}
+
+	jm.Drain()
+	if err := jm.Trigger("ping"); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Trigger while draining returned %v, want ErrShuttingDown", err)
+	}
+}
+
+func TestConcurrencyPolicies(t *testing.T) {
+	tests := []struct {
+		policy    ConcurrencyPolicy
+		triggers  int
+		succeeded int
+		skipped   int
+		cancelled int
+	}{
+		// The second run is dropped while the first one holds the slot
+		{policy: ConcurrencySkip, triggers: 2, succeeded: 1, skipped: 1},
+		// The second run waits for the first one, the third finds the queue full
+		{policy: ConcurrencyQueue, triggers: 3, succeeded: 2, skipped: 1},
+		// The second run cancels the first one
+		{policy: ConcurrencyReplace, triggers: 2, succeeded: 1, cancelled: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			jm, _ := newTestManager(t)
+			started := make(chan struct{}, tt.triggers)
+			release := make(chan struct{})
+			endpoint := blockingEndpoint(t, started, release)
+
+			if _, err := jm.Register(Job{Name: "slow", Cron: "0 0 1 1 *", Endpoint: endpoint, Concurrency: tt.policy}); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+			if err := jm.Trigger("slow"); err != nil {
+				t.Fatalf("Trigger: %v", err)
+			}
+			<-started
+
+			for range tt.triggers - 1 {
+				if err := jm.Trigger("slow"); err != nil {
+					t.Fatalf("Trigger: %v", err)
+				}
+			}
+			switch tt.policy {
+			case ConcurrencyReplace:
+				<-started
+			default:
+				waitFor(t, "the extra run to be skipped", func() bool {
+					return countExecutions(t, jm, "slow", statusFilter(ExecutionSkipped)) == tt.skipped
+				})
+			}
+			close(release)
+
+			waitFor(t, "the runs to finish", func() bool {
+				return countExecutions(t, jm, "slow", statusFilter(ExecutionSucceeded)) == tt.succeeded
+			})
+			if got := countExecutions(t, jm, "slow", statusFilter(ExecutionSkipped)); got != tt.skipped {
+				t.Errorf("%d skipped runs, want %d", got, tt.skipped)
+			}
+			if got := countExecutions(t, jm, "slow", statusFilter(ExecutionCancelled)); got != tt.cancelled {
+				t.Errorf("%d cancelled runs, want %d", got, tt.cancelled)
+			}
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	jm, _ := newTestManager(t)
+	var calls atomic.Int32
+	endpoint := newEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	retry := &RetryPolicy{MaxAttempts: 3, InitialDelay: Duration(10 * time.Millisecond)}
+	if _, err := jm.Register(Job{Name: "flaky", Cron: "0 0 1 1 *", Endpoint: endpoint, Retry: retry}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := jm.Trigger("flaky"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+
+	waitFor(t, "the third attempt to succeed", func() bool {
+		return countExecutions(t, jm, "flaky", statusFilter(ExecutionSucceeded)) == 1
+	})
+	executions, _, _ := jm.Executions("flaky", ExecutionFilter{})
+	if len(executions) != 3 {
+		t.Fatalf("%d executions recorded, want one per attempt", len(executions))
+	}
+	// Executions are listed newest first
+	for i, exec := range executions {
+		want := ExecutionFailed
+		if i == 0 {
+			want = ExecutionSucceeded
+		}
+		if exec.Attempt != 3-i || exec.Status != want {
+			t.Errorf("execution %d is attempt %d %s, want attempt %d %s", i, exec.Attempt, exec.Status, 3-i, want)
+		}
+	}
+
+	item, _ := jm.Get("flaky")
+	if item.LastStatus != ExecutionSucceeded || item.FailureStreak != 0 {
+		t.Errorf("job state is %s with a failure streak of %d, want succeeded", item.LastStatus, item.FailureStreak)
+	}
+}
+
+func TestMisfireCatchUp(t *testing.T) {
+	tests := []struct {
+		policy MisfirePolicy
+		limit  int
+		runs   int
+	}{
+		{policy: MisfireIgnore, runs: 0},
+		{policy: MisfireRunOnce, runs: 1},
+		{policy: MisfireRunAll, limit: 2, runs: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			jm, store := newTestManager(t)
+			var calls atomic.Int32
+			endpoint := okEndpoint(t, &calls)
+
+			// Runs every hour, last run three hours ago, as if the service had been down since
+			job := Job{Name: "hourly", Cron: "0 * * * *", Endpoint: endpoint, Misfire: tt.policy, MisfireLimit: tt.limit}
+			if err := store.InsertJob(job); err != nil {
+				t.Fatalf("InsertJob: %v", err)
+			}
+			lastRun := time.Now().Add(-3 * time.Hour)
+			if err := store.SetJobLastRun("hourly", lastRun); err != nil {
+				t.Fatalf("SetJobLastRun: %v", err)
+			}
+
+			if err := jm.LoadJobs(); err != nil {
+				t.Fatalf("LoadJobs: %v", err)
+			}
+			jm.StartScheduling()
+
+			misfires := ExecutionFilter{Trigger: TriggerMisfire, Statuses: []ExecutionStatus{ExecutionSucceeded}}
+			waitFor(t, "the catch-up runs", func() bool {
+				return countExecutions(t, jm, "hourly", misfires) == tt.runs
+			})
+			// Leaves time for any extra run to show up
+			time.Sleep(100 * time.Millisecond)
+			if got := countExecutions(t, jm, "hourly", misfires); got != tt.runs {
+				t.Errorf("%d catch-up runs, want %d", got, tt.runs)
+			}
+		})
+	}
+}
+
+func TestOnceCompletes(t *testing.T) {
+	jm, store := newTestManager(t)
+	var calls atomic.Int32
+	endpoint := okEndpoint(t, &calls)
+	jm.StartScheduling()
+
+	runAt := time.Now().Add(time.Second).Truncate(time.Second).Add(time.Second)
+	if _, err := jm.Register(Job{Name: "once", Type: JobTypeOnce, RunAt: &runAt, Endpoint: endpoint}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	waitFor(t, "the one-shot job to complete", func() bool {
+		item, err := jm.Get("once")
+		return err == nil && item.CompletedAt != nil
+	})
+	if calls.Load() != 1 {
+		t.Errorf("endpoint was called %d times, want 1", calls.Load())
+	}
+	if stored, _ := store.LoadJobs(); stored[0].Job.CompletedAt == nil {
+		t.Errorf("store does not hold the job as completed")
+	}
+	if _, err := jm.Update(Job{Name: "once", Method: http.MethodPost}); !errors.Is(err, ErrInvalidJob) {
+		t.Errorf("Update of a completed job returned %v, want ErrInvalidJob", err)
+	}
+}
+
+func TestOnceCompletesWhenSkipped(t *testing.T) {
+	jm, _ := newTestManager(t)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	endpoint := blockingEndpoint(t, started, release)
+	defer close(release)
+	jm.StartScheduling()
+
+	runAt := time.Now().Add(time.Second).Truncate(time.Second).Add(time.Second)
+	job := Job{Name: "once", Type: JobTypeOnce, RunAt: &runAt, Endpoint: endpoint, Concurrency: ConcurrencySkip}
+	if _, err := jm.Register(job); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	// A manual run still going when the job is due makes the skip policy drop the scheduled one
+	if err := jm.Trigger("once"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	<-started
+
+	waitFor(t, "the skipped one-shot job to complete", func() bool {
+		item, err := jm.Get("once")
+		return err == nil && item.CompletedAt != nil
+	})
+	if got := countExecutions(t, jm, "once", statusFilter(ExecutionSkipped)); got != 1 {
+		t.Errorf("%d skipped runs, want 1", got)
+	}
+}
+
+func TestSyncPicksUpChangesOfOtherInstances(t *testing.T) {
+	leader, store := newTestManager(t)
+	follower := NewJobManager(store)
+	endpoint := newEndpoint(t, func(http.ResponseWriter, *http.Request) {})
+	leader.StartScheduling()
+
+	if _, err := follower.Register(Job{Name: "shared", Cron: "0 * * * *", Endpoint: endpoint}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := leader.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	item, err := leader.Get("shared")
+	if err != nil || item.NextRun == nil {
+		t.Fatalf("leader has %+v (%v) after Sync, want the job scheduled", item, err)
+	}
+
+	if _, err := follower.Update(Job{Name: "shared", Cron: "30 * * * *"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	leader.Sync()
+	if item, _ := leader.Get("shared"); item.Cron != "30 * * * *" || item.NextRun == nil || item.NextRun.Minute() != 30 {
+		t.Errorf("leader has cron %q next running at %v after Sync, want the updated schedule", item.Cron, item.NextRun)
+	}
+
+	if err := follower.Deregister("shared"); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	leader.Sync()
+	if _, err := leader.Get("shared"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("leader still has the job after Sync: %v", err)
+	}
+}
+
+func TestReplaceCancelsEveryRunInProgress(t *testing.T) {
+	jm, _ := newTestManager(t)
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	endpoint := blockingEndpoint(t, started, release)
+	defer close(release)
+
+	// Two runs go on side by side under the allow policy
+	if _, err := jm.Register(Job{Name: "slow", Cron: "0 0 1 1 *", Endpoint: endpoint}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	for range 2 {
+		if err := jm.Trigger("slow"); err != nil {
+			t.Fatalf("Trigger: %v", err)
+		}
+		<-started
+	}
+
+	if _, err := jm.Update(Job{Name: "slow", Concurrency: ConcurrencyReplace}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := jm.Trigger("slow"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	<-started
+
+	waitFor(t, "both earlier runs to be cancelled", func() bool {
+		return countExecutions(t, jm, "slow", statusFilter(ExecutionCancelled)) == 2
+	})
+}
+
+func TestDrainRefusesChanges(t *testing.T) {
+	jm, store := newTestManager(t)
+	endpoint := newEndpoint(t, func(http.ResponseWriter, *http.Request) {})
+	if _, err := jm.Register(Job{Name: "active", Cron: "0 * * * *", Endpoint: endpoint}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := jm.Register(Job{Name: "paused", Cron: "0 * * * *", Endpoint: endpoint, Paused: true}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	jm.Drain()
+
+	// Kafka commands reach the manager directly, so it refuses them itself
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{name: "register", change: func() error {
+			_, err := jm.Register(Job{Name: "new", Cron: "0 * * * *", Endpoint: endpoint})
+			return err
+		}},
+		{name: "update", change: func() error {
+			_, err := jm.Update(Job{Name: "active", Cron: "30 * * * *"})
+			return err
+		}},
+		{name: "pause", change: func() error { return jm.Pause("active") }},
+		{name: "resume", change: func() error { return jm.Resume("paused") }},
+		{name: "deregister", change: func() error { return jm.Deregister("active") }},
+		{name: "trigger", change: func() error { return jm.Trigger("active") }},
+	}
+	for _, tt := range changes {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); !errors.Is(err, ErrShuttingDown) {
+				t.Errorf("%s while draining returned %v, want ErrShuttingDown", tt.name, err)
+			}
+		})
+	}
+
+	stored, _ := store.LoadJobs()
+	if len(stored) != 2 {
+		t.Fatalf("store holds %d jobs, want the 2 registered before draining", len(stored))
+	}
+	for _, s := range stored {
+		if s.Job.Cron != "0 * * * *" || s.Job.Paused != (s.Job.Name == "paused") {
+			t.Errorf("store holds %+v, want the job unchanged", s.Job)
+		}
+	}
+}
+
+// gatherDuration returns the exposed duration histogram of a job, or nil if it has none
+func gatherDuration(t *testing.T, jobName string) *dto.Histogram {
+	t.Helper()
+	metrics.Init()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != string(metrics.ExecutionDuration) {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if m.GetLabel()[0].GetValue() == jobName {
+				return m.GetHistogram()
+			}
+		}
+	}
+	return nil
+}
+
+func TestLoadMetricsRestoresDurations(t *testing.T) {
+	bucket := func(seconds float64) metrics.MetricName {
+		name, _ := metrics.DurationBucket(seconds)
+		return name
+	}
+
+	tests := []struct {
+		name    string
+		stored  map[metrics.MetricName]float64
+		count   uint64
+		sum     float64
+		buckets map[float64]uint64
+	}{
+		{
+			name: "with buckets",
+			stored: map[metrics.MetricName]float64{
+				metrics.ExecutionDurationCount: 3,
+				metrics.ExecutionDurationSum:   1.5,
+				bucket(0.05):                   1,
+				bucket(0.5):                    2,
+			},
+			count: 3, sum: 1.5,
+			buckets: map[float64]uint64{0.1: 1, 0.6: 3, 4.6: 3},
+		},
+		{
+			// Databases written before the buckets were stored put every run in the bucket of the mean
+			name: "without buckets",
+			stored: map[metrics.MetricName]float64{
+				metrics.ExecutionDurationCount: 4,
+				metrics.ExecutionDurationSum:   4,
+			},
+			count: 4, sum: 4,
+			buckets: map[float64]uint64{0.6: 0, 1.1: 4, 4.6: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobName := "durations " + tt.name
+			store := NewMemoryStore()
+			for name, value := range tt.stored {
+				store.AddJobMetric(name, jobName, value)
+			}
+			LoadMetricsFromDB(store)
+
+			h := gatherDuration(t, jobName)
+			if h == nil {
+				t.Fatalf("no duration histogram for %s", jobName)
+			}
+			if h.GetSampleCount() != tt.count || h.GetSampleSum() != tt.sum {
+				t.Errorf("histogram has count %d and sum %g, want %d and %g", h.GetSampleCount(), h.GetSampleSum(), tt.count, tt.sum)
+			}
+			counts := make(map[float64]uint64)
+			for _, b := range h.GetBucket() {
+				counts[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			for bound, want := range tt.buckets {
+				if counts[bound] != want {
+					t.Errorf("bucket le=%g counts %d, want %d", bound, counts[bound], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunsStoreTheirDurationBucket(t *testing.T) {
+	jm, store := newTestManager(t)
+	var calls atomic.Int32
+	if _, err := jm.Register(Job{Name: "timed", Cron: "0 0 1 1 *", Endpoint: okEndpoint(t, &calls)}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := jm.Trigger("timed"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	waitFor(t, "the run to succeed", func() bool {
+		return countExecutions(t, jm, "timed", statusFilter(ExecutionSucceeded)) == 1
+	})
+
+	stored, err := store.LoadMetrics()
+	if err != nil {
+		t.Fatalf("LoadMetrics: %v", err)
+	}
+	values := make(map[metrics.MetricName]float64)
+	var buckets float64
+	for _, metric := range stored {
+		if metric.JobName != "timed" {
+			continue
+		}
+		values[metric.Name] = metric.Value
+		if _, ok := metrics.ParseDurationBucket(metric.Name); ok {
+			buckets += metric.Value
+		}
+	}
+	if values[metrics.ExecutionDurationCount] != 1 || buckets != 1 {
+		t.Errorf("stored duration count %g and %g bucket count(s), want 1 of each", values[metrics.ExecutionDurationCount], buckets)
+	}
+}
@@ -0,0 +1,230 @@
+package jobs
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"schedulerservice/internal/metrics"
+)
+
+// MemoryStore is a Store kept in memory, for tests and throwaway instances
+type MemoryStore struct {
+	mu         sync.Mutex
+	jobs       map[string]StoredJob
+	executions []Execution
+	lastExecID int64
+	metrics    map[memoryMetricKey]float64
+}
+
+type memoryMetricKey struct {
+	name    metrics.MetricName
+	jobName string
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a MemoryStore with no jobs and the global metrics at zero
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		jobs:    make(map[string]StoredJob),
+		metrics: make(map[memoryMetricKey]float64),
+	}
+	for _, name := range GlobalMetrics {
+		s.metrics[memoryMetricKey{name: name}] = 0
+	}
+	return s
+}
+
+// LoadJobs returns every stored job, sorted by name
+func (s *MemoryStore) LoadJobs() ([]StoredJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := make([]StoredJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		stored = append(stored, job)
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].Job.Name < stored[j].Job.Name
+	})
+	return stored, nil
+}
+
+// InsertJob stores a new job definition
+func (s *MemoryStore) InsertJob(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("job %q %w", job.Name, ErrJobExists)
+	}
+	s.jobs[job.Name] = StoredJob{Job: job, CreatedAt: time.Now().UTC()}
+	return nil
+}
+
+// UpdateJob replaces the stored definition of a job, keeping its runtime state
+func (s *MemoryStore) UpdateJob(job Job) error {
+	return s.modify(job.Name, func(stored *StoredJob) {
+		stored.Job = job
+	})
+}
+
+// DeleteJob removes a job definition
+func (s *MemoryStore) DeleteJob(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, name)
+	return nil
+}
+
+// UpdateJobState stores the outcome of the latest run of a job
+func (s *MemoryStore) UpdateJobState(name string, state JobState) error {
+	return s.modify(name, func(stored *StoredJob) {
+		stored.State.LastStatus = state.LastStatus
+		stored.State.LastDuration = state.LastDuration
+		stored.State.FailureStreak = state.FailureStreak
+	})
+}
+
+// SetJobLastRun stores when the latest scheduled run of a job started
+func (s *MemoryStore) SetJobLastRun(name string, lastRunAt time.Time) error {
+	return s.modify(name, func(stored *StoredJob) {
+		lastRunAt = lastRunAt.UTC()
+		stored.State.LastRunAt = &lastRunAt
+	})
+}
+
+// ClaimRun stores scheduledAt as the latest scheduled run of a job unless a later one is stored
+func (s *MemoryStore) ClaimRun(name string, scheduledAt time.Time) (bool, error) {
+	claimed := false
+	err := s.modify(name, func(stored *StoredJob) {
+		if last := stored.State.LastRunAt; last != nil && !last.Before(scheduledAt) {
+			return
+		}
+		scheduledAt = scheduledAt.UTC()
+		stored.State.LastRunAt = &scheduledAt
+		claimed = true
+	})
+	return claimed, err
+}
+
+// SetJobCompleted marks a one-shot job as done
+func (s *MemoryStore) SetJobCompleted(name string, completedAt time.Time) error {
+	return s.modify(name, func(stored *StoredJob) {
+		completedAt = completedAt.UTC()
+		stored.Job.CompletedAt = &completedAt
+	})
+}
+
+// SetJobPaused stores the paused state of a job
+func (s *MemoryStore) SetJobPaused(name string, paused bool) error {
+	return s.modify(name, func(stored *StoredJob) {
+		stored.Job.Paused = paused
+	})
+}
+
+// modify applies change to a stored job, failing with ErrJobNotFound if there is none
+func (s *MemoryStore) modify(name string, change func(*StoredJob)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.jobs[name]
+	if !exists {
+		return fmt.Errorf("job %q %w", name, ErrJobNotFound)
+	}
+	change(&stored)
+	s.jobs[name] = stored
+	return nil
+}
+
+// StartExecution stores a new execution and sets its ID
+func (s *MemoryStore) StartExecution(exec *Execution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastExecID++
+	exec.ID = s.lastExecID
+	s.executions = append(s.executions, *exec)
+	return nil
+}
+
+// FinishExecution stores the final state of an execution
+func (s *MemoryStore) FinishExecution(exec *Execution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.executions {
+		if s.executions[i].ID == exec.ID {
+			s.executions[i] = *exec
+			return nil
+		}
+	}
+	return fmt.Errorf("execution %d does not exist", exec.ID)
+}
+
+// ListExecutions returns a page of the execution history of a job, newest first,
+// along with the total number of executions matching the filter
+func (s *MemoryStore) ListExecutions(name string, filter ExecutionFilter) ([]Execution, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []Execution
+	for _, exec := range s.executions {
+		if exec.JobName != name {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, exec.Status) {
+			continue
+		}
+		if filter.Trigger != "" && exec.Trigger != filter.Trigger {
+			continue
+		}
+		matching = append(matching, exec)
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		if !matching[i].StartedAt.Equal(matching[j].StartedAt) {
+			return matching[i].StartedAt.After(matching[j].StartedAt)
+		}
+		return matching[i].ID > matching[j].ID
+	})
+
+	start := min(filter.Offset, len(matching))
+	end := len(matching)
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, len(matching))
+	}
+	return matching[start:end], len(matching), nil
+}
+
+// LoadMetrics returns the stored metric values
+func (s *MemoryStore) LoadMetrics() ([]Metric, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := make([]Metric, 0, len(s.metrics))
+	for key, value := range s.metrics {
+		stored = append(stored, Metric{Name: key.name, JobName: key.jobName, Value: value})
+	}
+	return stored, nil
+}
+
+// AddGlobalMetric adds value to a service-wide metric
+func (s *MemoryStore) AddGlobalMetric(name metrics.MetricName, value float64) error {
+	return s.AddJobMetric(name, "", value)
+}
+
+// AddJobMetric adds value to a metric of a single job
+func (s *MemoryStore) AddJobMetric(name metrics.MetricName, jobName string, value float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics[memoryMetricKey{name: name, jobName: jobName}] += value
+	return nil
+}
+
+// Close does nothing, as there is nothing to release
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"schedulerservice/internal/metrics"
+)
+
+// MisfirePolicy decides what happens to the runs a job missed while the service was down
+type MisfirePolicy string
+
+const (
+	// MisfireIgnore drops missed runs
+	MisfireIgnore MisfirePolicy = "ignore"
+	// MisfireRunOnce makes up for any number of missed runs with a single run
+	MisfireRunOnce MisfirePolicy = "run_once"
+	// MisfireRunAll runs every missed run, up to the job's misfire limit
+	MisfireRunAll MisfirePolicy = "run_all"
+)
+
+const (
+	defaultMisfireLimit = 10
+	maxMisfireLimit     = 100
+)
+
+// validateMisfire normalizes the misfire policy of a job, defaulting to ignore
+func validateMisfire(job *Job) error {
+	job.Misfire = MisfirePolicy(strings.ToLower(strings.TrimSpace(string(job.Misfire))))
+	switch job.Misfire {
+	case "":
+		job.Misfire = MisfireIgnore
+	case MisfireIgnore, MisfireRunOnce, MisfireRunAll:
+	default:
+		return fmt.Errorf("invalid misfire policy %q", job.Misfire)
+	}
+	if job.MisfireLimit < 0 || job.MisfireLimit > maxMisfireLimit {
+		return fmt.Errorf("misfire_limit must be between 0 and %d", maxMisfireLimit)
+	}
+	return nil
+}
+
+// missedRuns counts the scheduled fire times of a cron job after since and up to now,
+// stopping once limit is exceeded
+func missedRuns(job Job, since, now time.Time, limit int) (int, error) {
+	schedule, err := cronParser.Parse(job.spec())
+	if err != nil {
+		return 0, fmt.Errorf("invalid cron: %w", err)
+	}
+
+	missed := 0
+	for next := schedule.Next(since); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		missed++
+		if missed > limit {
+			break
+		}
+	}
+	return missed, nil
+}
+
+// catchUp applies the misfire policy of a cron job whose last run was at lastRunAt.
+// Catch-up runs are made one after another in the background. The caller must hold jm.mu
+func (jm *JobManager) catchUp(entry *jobEntry, lastRunAt time.Time) {
+	job := entry.job
+	limit := job.MisfireLimit
+	if limit == 0 {
+		limit = defaultMisfireLimit
+	}
+
+	missed, err := missedRuns(job, lastRunAt, time.Now(), limit)
+	if err != nil {
+		log.Printf("[WARN] Failed to check missed runs of job %s: %v", job.Name, err)
+		return
+	}
+	if missed == 0 {
+		return
+	}
+
+	runs := 0
+	switch job.Misfire {
+	case MisfireRunOnce:
+		runs = 1
+	case MisfireRunAll:
+		runs = min(missed, limit)
+	}
+	log.Printf("[JOB] Job %s missed %d run(s) since %s, %s policy makes up for %d",
+		job.Name, missed, lastRunAt.Format(time.RFC3339), job.Misfire, runs)
+	metrics.JobMissedRuns.WithLabelValues(job.Name).Add(float64(missed))
+	if runs == 0 {
+		return
+	}
+
+	go func() {
+		for range runs {
+			jm.run(entry, TriggerMisfire)
+		}
+	}()
+}
@@ -1,21 +1,126 @@
 package jobs
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
 type JobManager struct {
-	mu   sync.Mutex
-	cron *cron.Cron
-	jobs map[string]cron.EntryID
+	mu    sync.Mutex
+	store Store
+	cron  *cron.Cron
+	jobs  map[string]*jobEntry
+	// scheduling is set while this instance runs the cron scheduler, i.e. while it is the leader
+	scheduling bool
+
+	// runMu guards closing and the additions to runs, so no run starts once ShutDown waits for them
+	runMu sync.Mutex
+	// closing is set by ShutDown, after which no new run starts
+	closing bool
+	// runs counts the runs in progress
+	runs sync.WaitGroup
+	// runCtx is the parent of the context of every run, cancelled by stopRuns to abort them
+	runCtx   context.Context
+	stopRuns context.CancelFunc
+
+	// events receives the changes to jobs and executions
+	events EventPublisher
+	// executors make the calls of the jobs, by target type
+	executors map[TargetType]Executor
+}
+
+// jobEntry is the runtime state of a registered job
+type jobEntry struct {
+	job       Job
+	id        cron.EntryID
+	createdAt time.Time
+
+	// slot holds a token while a run is in progress, for policies other than allow
+	slot   chan struct{}
+	mu     sync.Mutex
+	queued bool
+	// cancels holds the cancel func of each run in progress, keyed by run number
+	cancels map[uint64]context.CancelFunc
+	lastRun uint64
+	state   JobState
+}
+
+// JobState is the outcome of the latest finished run of a job
+type JobState struct {
+	LastStatus    ExecutionStatus
+	LastDuration  float64
+	FailureStreak int
+	// LastRunAt is when the latest scheduled run started, used to find runs missed during downtime
+	LastRunAt *time.Time
 }
 
 type Job struct {
-	Name     string `json:"name"`
-	Cron     string `json:"cron"`
-	Endpoint string `json:"endpoint"`
+	Name     string            `json:"name"`
+	Type     JobType           `json:"type,omitempty"`
+	Cron     string            `json:"cron,omitempty"`
+	Timezone string            `json:"timezone,omitempty"`
+	Endpoint string            `json:"endpoint,omitempty"`
+	Method   string            `json:"method,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Query    map[string]string `json:"query,omitempty"`
+	// Body is sent as JSON, unless it is a JSON string, whose contents are sent raw
+	Body    json.RawMessage `json:"body,omitempty"`
+	Timeout Duration        `json:"timeout,omitempty"`
+	Retry   *RetryPolicy    `json:"retry,omitempty"`
+
+	// Target is the kind of call made on each run: http, the default, makes the request
+	// described above, while kafka produces the message described by Kafka
+	Target TargetType   `json:"target,omitempty"`
+	Kafka  *KafkaTarget `json:"kafka,omitempty"`
+
+	Concurrency ConcurrencyPolicy `json:"concurrency,omitempty"`
+	Paused      bool              `json:"paused,omitempty"`
+
+	// Misfire decides what happens to runs missed while the service was down;
+	// MisfireLimit caps the runs made up for by the run_all policy
+	Misfire      MisfirePolicy `json:"misfire,omitempty"`
+	MisfireLimit int           `json:"misfire_limit,omitempty"`
+
+	// RunAt and Delay schedule a one-shot job; a delay is turned into RunAt on registration
+	RunAt       *time.Time `json:"run_at,omitempty"`
+	Delay       Duration   `json:"delay,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Duration is a time.Duration that is encoded in JSON as a string like "1m30s".
+// Plain JSON numbers are read as seconds
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+	return nil
 }
 
 type JobName struct {
@@ -29,10 +134,24 @@ type JobResponse struct {
 	Job     Job    `json:"job"`
 }
 
+// JobListItem is the stored definition of a job along with its runtime state
 type JobListItem struct {
-	Name     string        `json:"name"`
-	Cron     cron.Schedule `json:"cron"`
-	Endpoint cron.Job      `json:"endpoint"`
+	Job
+	Paused        bool            `json:"paused"`
+	Format        ScheduleFormat  `json:"schedule_format"`
+	NextRun       *time.Time      `json:"next_run,omitempty"`
+	NextRunLocal  *time.Time      `json:"next_run_local,omitempty"`
+	PrevRun       *time.Time      `json:"prev_run,omitempty"`
+	LastStatus    ExecutionStatus `json:"last_status,omitempty"`
+	LastDuration  float64         `json:"last_duration_seconds,omitempty"`
+	FailureStreak int             `json:"failure_streak"`
+}
+
+type JobDetailResponse struct {
+	Status  string      `json:"status"`
+	Name    string      `json:"name"`
+	Message string      `json:"message"`
+	Job     JobListItem `json:"job"`
 }
 
 type JobListResponse struct {
@@ -43,6 +162,150 @@ type JobListResponse struct {
 }
 
 type JobRegistrar interface {
-	Register(Job) error
+	Register(Job) (Job, error)
 	Deregister(string) error
+	Update(Job) (Job, error)
+	Trigger(string) error
+	Pause(string) error
+	Resume(string) error
+	Get(string) (JobListItem, error)
+}
+
+var (
+	ErrJobNotFound = errors.New("does not exist")
+	ErrJobExists   = errors.New("already exists")
+	// ErrInvalidJob matches the errors caused by an invalid job definition or request
+	ErrInvalidJob = errors.New("invalid job")
+	// ErrShuttingDown is returned for the runs refused once the manager is draining
+	ErrShuttingDown = errors.New("service is shutting down")
+)
+
+// invalidJobError is a problem with a job definition or request. It keeps the message
+// of err and matches both err and ErrInvalidJob
+type invalidJobError struct {
+	err error
+}
+
+func (e *invalidJobError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidJobError) Unwrap() []error {
+	return []error{ErrInvalidJob, e.err}
+}
+
+// invalidJob marks err as caused by the job definition or request
+func invalidJob(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &invalidJobError{err: err}
+}
+
+// IsPermanent reports whether an error returned by the JobRegistrar methods is caused by
+// the request itself, such as an invalid definition or an unknown job, so that making the
+// same request again cannot succeed. Other errors, such as a failing store, are transient
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrInvalidJob) || errors.Is(err, ErrJobNotFound) || errors.Is(err, ErrJobExists)
+}
+
+type ExecutionStatus string
+
+const (
+	ExecutionRunning   ExecutionStatus = "running"
+	ExecutionSucceeded ExecutionStatus = "succeeded"
+	ExecutionFailed    ExecutionStatus = "failed"
+	ExecutionSkipped   ExecutionStatus = "skipped"
+	ExecutionCancelled ExecutionStatus = "cancelled"
+)
+
+// Valid reports whether s is a known execution status
+func (s ExecutionStatus) Valid() bool {
+	switch s {
+	case ExecutionRunning, ExecutionSucceeded, ExecutionFailed, ExecutionSkipped, ExecutionCancelled:
+		return true
+	}
+	return false
+}
+
+// Execution is a single run of a job as stored in the job_executions table
+type Execution struct {
+	ID           int64           `json:"id"`
+	JobName      string          `json:"job_name"`
+	Attempt      int             `json:"attempt"`
+	Trigger      Trigger         `json:"trigger"`
+	Status       ExecutionStatus `json:"status"`
+	StatusCode   int             `json:"status_code,omitempty"`
+	Duration     float64         `json:"duration_seconds"`
+	Error        string          `json:"error,omitempty"`
+	ResponseBody string          `json:"response_body,omitempty"`
+	StartedAt    time.Time       `json:"started_at"`
+	FinishedAt   *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Trigger tells what started a run
+type Trigger string
+
+const (
+	TriggerScheduled Trigger = "scheduled"
+	TriggerManual    Trigger = "manual"
+	// TriggerMisfire marks a run making up for one missed while the service was down
+	TriggerMisfire Trigger = "misfire"
+)
+
+// Valid reports whether t is a known trigger
+func (t Trigger) Valid() bool {
+	return t == TriggerScheduled || t == TriggerManual || t == TriggerMisfire
+}
+
+// ExecutionFilter selects a page of a job's execution history
+type ExecutionFilter struct {
+	Statuses []ExecutionStatus
+	Trigger  Trigger
+	Limit    int
+	Offset   int
+}
+
+type ExecutionListResponse struct {
+	Status     string      `json:"status"`
+	Name       string      `json:"name"`
+	Message    string      `json:"message"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+	Executions []Execution `json:"executions"`
+}
+
+// ScheduleFormat is the form a cron expression is written in
+type ScheduleFormat string
+
+const (
+	// ScheduleStandard is the 5-field form: minute, hour, day of month, month, day of week
+	ScheduleStandard ScheduleFormat = "standard"
+	// ScheduleSeconds is the 6-field form with a leading seconds field
+	ScheduleSeconds ScheduleFormat = "seconds"
+	// ScheduleDescriptor is a descriptor such as @daily or @every 30s
+	ScheduleDescriptor ScheduleFormat = "descriptor"
+	// ScheduleOnce is a one-shot job with a run_at time instead of a cron expression
+	ScheduleOnce ScheduleFormat = "once"
+)
+
+type ScheduleRequest struct {
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone,omitempty"`
+	Count    int    `json:"count,omitempty"`
+}
+
+type SchedulePreview struct {
+	Cron          string         `json:"cron"`
+	Timezone      string         `json:"timezone"`
+	Format        ScheduleFormat `json:"format"`
+	NextRuns      []time.Time    `json:"next_runs"`
+	NextRunsLocal []time.Time    `json:"next_runs_local"`
+}
+
+type ScheduleResponse struct {
+	Status   string          `json:"status"`
+	Message  string          `json:"message"`
+	Schedule SchedulePreview `json:"schedule"`
 }
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"schedulerservice/internal/metrics"
+)
+
+// JobType tells whether a job runs on a recurring cron schedule or once
+type JobType string
+
+const (
+	JobTypeCron JobType = "cron"
+	JobTypeOnce JobType = "once"
+)
+
+// onceSchedule is a cron.Schedule that fires a single time
+type onceSchedule struct {
+	at time.Time
+}
+
+// Next returns the fire time while it is still ahead of t, and the zero time afterwards,
+// which the cron scheduler treats as never
+func (s onceSchedule) Next(t time.Time) time.Time {
+	if t.Before(s.at) {
+		return s.at
+	}
+	return time.Time{}
+}
+
+// validateOnce checks a one-shot job and turns a delay into an absolute run_at
+func validateOnce(job *Job) error {
+	if job.Cron != "" {
+		return fmt.Errorf("one-shot jobs take run_at or delay instead of cron")
+	}
+	if job.Delay != 0 {
+		if job.RunAt != nil {
+			return fmt.Errorf("only one of run_at and delay can be set")
+		}
+		if job.Delay < 0 {
+			return fmt.Errorf("delay must not be negative")
+		}
+		runAt := time.Now().Add(time.Duration(job.Delay)).UTC().Truncate(time.Second)
+		job.RunAt = &runAt
+		job.Delay = 0
+	}
+	if job.RunAt == nil {
+		return fmt.Errorf("one-shot jobs need run_at or delay")
+	}
+	return nil
+}
+
+// complete marks the one-shot job called name, as captured when its run started, as done.
+// It keeps the job, so its state and execution history can still be looked up
+func (jm *JobManager) complete(entry *jobEntry, name string) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if jm.jobs[name] != entry {
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := jm.store.SetJobCompleted(name, now); err != nil {
+		log.Printf("[ERROR] Failed to mark job %s as completed: %v", name, err)
+	}
+
+	jm.cron.Remove(entry.id)
+	entry.mu.Lock()
+	entry.id = 0
+	entry.job.CompletedAt = &now
+	entry.mu.Unlock()
+
+	metrics.JobsActive.Dec()
+	jm.addGlobalMetric(metrics.ActiveJobs, -1)
+	log.Printf("[JOB] Completed one-shot job %s", name)
+}
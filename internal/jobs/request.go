@@ -0,0 +1,138 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"schedulerservice/internal/auth"
+)
+
+const (
+	defaultJobTimeout = 30 * time.Second
+	maxJobTimeout     = 10 * time.Minute
+)
+
+var allowedMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+	http.MethodHead:   true,
+}
+
+// validateJob checks the job definition and fills in the request defaults
+func validateJob(job *Job) error {
+	if strings.TrimSpace(job.Name) == "" {
+		return fmt.Errorf("job name is required")
+	}
+
+	if err := validateSchedule(job); err != nil {
+		return err
+	}
+	if err := validateTarget(job); err != nil {
+		return err
+	}
+
+	if job.Timeout < 0 || time.Duration(job.Timeout) > maxJobTimeout {
+		return fmt.Errorf("timeout must be between 0 and %s", maxJobTimeout)
+	}
+	if err := validateRetryPolicy(job.Retry); err != nil {
+		return err
+	}
+	if err := validateConcurrency(job); err != nil {
+		return err
+	}
+	return validateMisfire(job)
+}
+
+// validateRequest checks the HTTP request of a job with the http target and fills in its method
+func validateRequest(job *Job) error {
+	endpoint, err := url.Parse(job.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return fmt.Errorf("invalid endpoint %q: must be an absolute http(s) URL", job.Endpoint)
+	}
+
+	job.Method = strings.ToUpper(strings.TrimSpace(job.Method))
+	if job.Method == "" {
+		job.Method = http.MethodGet
+	}
+	if !allowedMethods[job.Method] {
+		return fmt.Errorf("unsupported method %q", job.Method)
+	}
+
+	if len(job.Body) > 0 && !json.Valid(job.Body) {
+		return fmt.Errorf("body must be valid JSON or a JSON string")
+	}
+	return nil
+}
+
+// timeout returns the per-request timeout of the job
+func (job Job) timeout() time.Duration {
+	if job.Timeout <= 0 {
+		return defaultJobTimeout
+	}
+	return time.Duration(job.Timeout)
+}
+
+// newJobRequest builds the HTTP request described by the job
+func newJobRequest(ctx context.Context, job Job) (*http.Request, error) {
+	endpoint, err := url.Parse(job.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if len(job.Query) > 0 {
+		query := endpoint.Query()
+		for key, value := range job.Query {
+			query.Set(key, value)
+		}
+		endpoint.RawQuery = query.Encode()
+	}
+
+	body, contentType, err := requestBody(job.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	method := job.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	auth.AddAPIKeyToRequest(req)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, value := range job.Headers {
+		req.Header.Set(key, value)
+	}
+	return req, nil
+}
+
+// requestBody turns the stored job body into a request body and its content type
+func requestBody(raw json.RawMessage) (io.Reader, string, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, "", nil
+	}
+
+	if trimmed[0] == '"' {
+		var text string
+		if err := json.Unmarshal(trimmed, &text); err != nil {
+			return nil, "", fmt.Errorf("invalid raw body: %w", err)
+		}
+		return strings.NewReader(text), "text/plain; charset=utf-8", nil
+	}
+	return bytes.NewReader(trimmed), "application/json", nil
+}
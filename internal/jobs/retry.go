@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"time"
+)
+
+// ErrorKind classifies why an attempt failed, so a retry policy can decide whether to retry it
+type ErrorKind string
+
+const (
+	ErrorTimeout    ErrorKind = "timeout"
+	ErrorConnection ErrorKind = "connection"
+	ErrorStatus     ErrorKind = "status"
+	ErrorRequest    ErrorKind = "request"
+	// ErrorBroker is an error returned by the Kafka brokers to a job with the kafka target
+	ErrorBroker ErrorKind = "broker"
+)
+
+const (
+	maxRetryAttempts    = 10
+	defaultInitialDelay = time.Second
+	defaultMultiplier   = 2.0
+	defaultMaxDelay     = time.Minute
+)
+
+var (
+	defaultRetryStatuses = []int{408, 429, 500, 502, 503, 504}
+	defaultRetryErrors   = []ErrorKind{ErrorTimeout, ErrorConnection}
+)
+
+// RetryPolicy controls how a failed run of a job is retried. A nil policy or a
+// MaxAttempts of 0 or 1 disables retries. When neither RetryOnStatus nor
+// RetryOnErrors is set, timeouts, connection errors and 408, 429, 500, 502,
+// 503 and 504 responses are retried
+type RetryPolicy struct {
+	MaxAttempts   int         `json:"max_attempts"`
+	InitialDelay  Duration    `json:"initial_delay,omitempty"`
+	Multiplier    float64     `json:"multiplier,omitempty"`
+	MaxDelay      Duration    `json:"max_delay,omitempty"`
+	Jitter        float64     `json:"jitter,omitempty"`
+	RetryOnStatus []int       `json:"retry_on_status,omitempty"`
+	RetryOnErrors []ErrorKind `json:"retry_on_errors,omitempty"`
+}
+
+// validateRetryPolicy checks the retry policy of a job, if it has one
+func validateRetryPolicy(policy *RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxAttempts < 0 || policy.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("retry max_attempts must be between 0 and %d", maxRetryAttempts)
+	}
+	if policy.InitialDelay < 0 || policy.MaxDelay < 0 {
+		return fmt.Errorf("retry delays must not be negative")
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		return fmt.Errorf("retry multiplier must be at least 1")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+	for _, code := range policy.RetryOnStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid retry status code %d", code)
+		}
+	}
+	for _, kind := range policy.RetryOnErrors {
+		switch kind {
+		case ErrorTimeout, ErrorConnection, ErrorStatus, ErrorRequest, ErrorBroker:
+		default:
+			return fmt.Errorf("invalid retry error kind %q", kind)
+		}
+	}
+	return nil
+}
+
+// maxAttempts returns the total number of attempts allowed per run, including the first one
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// shouldRetry reports whether a failed attempt with the given status code and error kind is retried
+func (p *RetryPolicy) shouldRetry(statusCode int, kind ErrorKind) bool {
+	if p == nil {
+		return false
+	}
+	statuses, kinds := p.RetryOnStatus, p.RetryOnErrors
+	if len(statuses) == 0 && len(kinds) == 0 {
+		statuses, kinds = defaultRetryStatuses, defaultRetryErrors
+	}
+
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	if kind == ErrorStatus {
+		for _, code := range statuses {
+			if code == statusCode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// delay returns the wait before the attempt following the given one:
+// InitialDelay * Multiplier^(attempt-1), capped at MaxDelay and spread by Jitter
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	initial, multiplier, maxDelay := defaultInitialDelay, defaultMultiplier, defaultMaxDelay
+	if p.InitialDelay > 0 {
+		initial = time.Duration(p.InitialDelay)
+	}
+	if p.Multiplier > 0 {
+		multiplier = p.Multiplier
+	}
+	if p.MaxDelay > 0 {
+		maxDelay = time.Duration(p.MaxDelay)
+	}
+
+	delay := math.Min(float64(initial)*math.Pow(multiplier, float64(attempt-1)), float64(maxDelay))
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// classifyError returns the kind of a failed attempt
+func classifyError(err error, statusCode int) ErrorKind {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case statusCode != 0:
+		return ErrorStatus
+	case errors.As(err, new(*url.Error)):
+		return ErrorConnection
+	default:
+		return ErrorRequest
+	}
+}
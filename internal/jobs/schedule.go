@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	defaultPreviewCount = 5
+	maxPreviewCount     = 100
+)
+
+// cronParser accepts standard 5-field expressions, 6-field expressions whose first
+// field is the seconds, and descriptors such as @hourly or @every 30s
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// PreviewSchedule parses a cron expression in the given timezone and returns its
+// format and next count fire times
+func PreviewSchedule(req ScheduleRequest) (SchedulePreview, error) {
+	job := Job{Cron: req.Cron, Timezone: req.Timezone}
+	if err := validateSchedule(&job); err != nil {
+		return SchedulePreview{}, err
+	}
+	schedule, err := cronParser.Parse(job.spec())
+	if err != nil {
+		return SchedulePreview{}, fmt.Errorf("invalid cron: %w", err)
+	}
+
+	count := req.Count
+	if count <= 0 {
+		count = defaultPreviewCount
+	}
+	if count > maxPreviewCount {
+		count = maxPreviewCount
+	}
+
+	preview := SchedulePreview{
+		Cron:          job.Cron,
+		Timezone:      job.location().String(),
+		Format:        scheduleFormat(job.Cron),
+		NextRuns:      make([]time.Time, 0, count),
+		NextRunsLocal: make([]time.Time, 0, count),
+	}
+	next := time.Now()
+	for range count {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			break
+		}
+		preview.NextRuns = append(preview.NextRuns, next.UTC())
+		preview.NextRunsLocal = append(preview.NextRunsLocal, next.In(job.location()))
+	}
+	return preview, nil
+}
+
+// format tells how the job is scheduled
+func (job Job) format() ScheduleFormat {
+	if job.Type == JobTypeOnce {
+		return ScheduleOnce
+	}
+	return scheduleFormat(job.Cron)
+}
+
+// describeSchedule returns a short description of when the job runs, for logging
+func (job Job) describeSchedule() string {
+	if job.Type == JobTypeOnce {
+		return "once at " + job.RunAt.Format(time.RFC3339)
+	}
+	return job.Cron
+}
+
+// scheduleFormat tells which form a cron expression is written in
+func scheduleFormat(expr string) ScheduleFormat {
+	_, expr = splitCronZone(expr)
+	switch {
+	case strings.HasPrefix(expr, "@"):
+		return ScheduleDescriptor
+	case len(strings.Fields(expr)) == 6:
+		return ScheduleSeconds
+	default:
+		return ScheduleStandard
+	}
+}
+
+// validateSchedule checks the cron expression and timezone of a job. A CRON_TZ= or TZ=
+// prefix in the expression sets the timezone when none is given, and must match it otherwise
+func validateSchedule(job *Job) error {
+	switch job.Type {
+	case "":
+		job.Type = JobTypeCron
+		if job.RunAt != nil || job.Delay != 0 {
+			job.Type = JobTypeOnce
+		}
+	case JobTypeCron, JobTypeOnce:
+	default:
+		return fmt.Errorf("invalid job type %q", job.Type)
+	}
+	if job.Type == JobTypeOnce {
+		return validateOnce(job)
+	}
+	if job.RunAt != nil || job.Delay != 0 {
+		return fmt.Errorf("run_at and delay are only supported by one-shot jobs")
+	}
+
+	job.Timezone = strings.TrimSpace(job.Timezone)
+	if prefixZone, _ := splitCronZone(job.Cron); prefixZone != "" {
+		if job.Timezone != "" && job.Timezone != prefixZone {
+			return fmt.Errorf("timezone %q does not match the cron prefix %q", job.Timezone, prefixZone)
+		}
+		job.Timezone = prefixZone
+	}
+
+	if job.Timezone != "" {
+		if _, err := time.LoadLocation(job.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", job.Timezone, err)
+		}
+	}
+
+	if _, err := cronParser.Parse(job.spec()); err != nil {
+		return fmt.Errorf("invalid cron: %w", err)
+	}
+	return nil
+}
+
+// spec returns the cron expression of the job with its timezone as a CRON_TZ= prefix
+func (job Job) spec() string {
+	if job.Timezone == "" {
+		return job.Cron
+	}
+	_, expr := splitCronZone(job.Cron)
+	return "CRON_TZ=" + job.Timezone + " " + expr
+}
+
+// location returns the timezone of the job, defaulting to UTC
+func (job Job) location() *time.Location {
+	if job.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// splitCronZone separates a CRON_TZ= or TZ= prefix from a cron expression
+func splitCronZone(expr string) (string, string) {
+	expr = strings.TrimSpace(expr)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(expr, prefix) {
+			zone, rest, _ := strings.Cut(strings.TrimPrefix(expr, prefix), " ")
+			return zone, strings.TrimSpace(rest)
+		}
+	}
+	return "", expr
+}
@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// StartScheduling starts running jobs on their schedules. It is called when this instance
+// becomes the leader: the jobs are reloaded from the database, which may have been changed
+// by the previous leader, and runs missed while no instance was scheduling are made up for
+func (jm *JobManager) StartScheduling() {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if stored, err := jm.readJobs(); err != nil {
+		log.Printf("[ERROR] Failed to reload jobs, scheduling the jobs already loaded: %v", err)
+	} else {
+		jm.reconcile(stored, true)
+	}
+
+	jm.scheduling = true
+	jm.cron.Start()
+	for _, entry := range jm.jobs {
+		jm.recover(entry)
+	}
+	log.Printf("[JOB] Scheduling %d job(s)", len(jm.jobs))
+}
+
+// SyncJobs reloads the jobs from the database every interval until ctx is done, so that
+// the jobs registered, changed or removed through the other instances sharing it reach
+// this one, and the leader schedules them
+func (jm *JobManager) SyncJobs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := jm.Sync(); err != nil {
+			log.Printf("[ERROR] Failed to sync jobs from database: %v", err)
+		}
+	}
+}
+
+// Sync reloads the jobs from the database once, keeping the entries of the jobs that did
+// not change
+func (jm *JobManager) Sync() error {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	stored, err := jm.readJobs()
+	if err != nil {
+		return err
+	}
+	jm.reconcile(stored, !jm.scheduling)
+	return nil
+}
+
+// reconcile brings the jobs of the manager in line with the stored ones: new jobs are
+// scheduled, changed ones get the stored definition and a new cron entry, and removed
+// ones are dropped. The entries of unchanged jobs are kept, so their runs in progress
+// still complete them. The state of a job is taken from the store when takeState is set;
+// otherwise it is left alone, as this instance makes the runs and keeps it up to date.
+// The caller must hold jm.mu
+func (jm *JobManager) reconcile(stored []StoredJob, takeState bool) {
+	found := make(map[string]bool, len(stored))
+	for _, s := range stored {
+		found[s.Job.Name] = true
+		entry, exists := jm.jobs[s.Job.Name]
+		if !exists {
+			if err := jm.schedule(s.Job); err != nil {
+				log.Printf("[WARN] Failed to schedule job %s: %v", s.Job.Name, err)
+				continue
+			}
+			entry = jm.jobs[s.Job.Name]
+			entry.state = s.State
+			entry.createdAt = s.CreatedAt
+			log.Printf("[JOB] Loaded %s (%s)", s.Job.Name, s.Job.describeSchedule())
+			continue
+		}
+
+		entry.mu.Lock()
+		if takeState {
+			entry.state = s.State
+		} else if s.State.LastRunAt != nil && (entry.state.LastRunAt == nil || s.State.LastRunAt.After(*entry.state.LastRunAt)) {
+			// A job resumed through another instance counts its runs from then on
+			entry.state.LastRunAt = s.State.LastRunAt
+		}
+		current := entry.job
+		entry.mu.Unlock()
+		if sameJob(current, s.Job) {
+			continue
+		}
+
+		jm.cron.Remove(entry.id)
+		entry.id = 0
+		entry.setJob(s.Job)
+		if !s.Job.Paused {
+			if err := jm.activate(entry); err != nil {
+				log.Printf("[WARN] Failed to schedule job %s: %v", s.Job.Name, err)
+			}
+		}
+		log.Printf("[JOB] Reloaded %s (%s)", s.Job.Name, s.Job.describeSchedule())
+	}
+
+	for name, entry := range jm.jobs {
+		if !found[name] {
+			jm.cron.Remove(entry.id)
+			delete(jm.jobs, name)
+			log.Printf("[JOB] Dropped %s, removed from the database", name)
+		}
+	}
+}
+
+// sameJob reports whether two job definitions are the same once stored. Times are
+// compared to the second, as the databases keep them with different precisions
+func sameJob(a, b Job) bool {
+	encodedA, errA := json.Marshal(storedTimes(a))
+	encodedB, errB := json.Marshal(storedTimes(b))
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// storedTimes returns job with its times in UTC and truncated to the second
+func storedTimes(job Job) Job {
+	for _, t := range []**time.Time{&job.RunAt, &job.CompletedAt} {
+		if *t != nil {
+			truncated := (*t).UTC().Truncate(time.Second)
+			*t = &truncated
+		}
+	}
+	return job
+}
+
+// StopScheduling stops running jobs on their schedules when this instance stops being
+// the leader. Runs in progress are left to finish
+func (jm *JobManager) StopScheduling() {
+	jm.mu.Lock()
+	jm.scheduling = false
+	jm.mu.Unlock()
+
+	jm.cron.Stop()
+	log.Printf("[JOB] Stopped scheduling jobs")
+}
+
+// recover makes up for the runs of a job missed while no instance was scheduling: a
+// one-shot job that is past due runs right away, and a cron job follows its misfire policy.
+// The caller must hold jm.mu
+func (jm *JobManager) recover(entry *jobEntry) {
+	job := entry.job
+	if job.Paused || job.CompletedAt != nil {
+		return
+	}
+
+	if job.Type == JobTypeOnce {
+		if !job.RunAt.After(time.Now()) {
+			log.Printf("[JOB] One-shot job %s missed its run at %s, running it now", job.Name, job.RunAt.Format(time.RFC3339))
+			go jm.run(entry, TriggerScheduled)
+		}
+		return
+	}
+
+	// Missed runs are counted from the latest scheduled run, or from the registration of a job that never ran
+	since := entry.createdAt
+	if entry.state.LastRunAt != nil {
+		since = *entry.state.LastRunAt
+	}
+	if !since.IsZero() {
+		jm.catchUp(entry, since)
+	}
+}
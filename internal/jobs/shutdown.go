@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// cancelGrace is how long cancelled runs get to record their executions once the
+// shutdown deadline has passed
+const cancelGrace = 5 * time.Second
+
+// beginRun counts a new run, unless the manager is shutting down
+func (jm *JobManager) beginRun() bool {
+	jm.runMu.Lock()
+	defer jm.runMu.Unlock()
+	if jm.closing {
+		return false
+	}
+	jm.runs.Add(1)
+	return true
+}
+
+// Drain stops the manager from starting new runs or changing jobs, whether through the
+// API or Kafka, and makes ShuttingDown report true, ahead of ShutDown. It is called as soon
+// as the service starts shutting down, so that it refuses new work while it deregisters itself
+func (jm *JobManager) Drain() {
+	jm.runMu.Lock()
+	jm.closing = true
+	jm.runMu.Unlock()
+}
+
+// refuseWhileDraining returns ErrShuttingDown once Drain has been called, so that jobs
+// are not changed while the service shuts down
+func (jm *JobManager) refuseWhileDraining(action, name string) error {
+	if jm.ShuttingDown() {
+		return fmt.Errorf("cannot %s job %q: %w", action, name, ErrShuttingDown)
+	}
+	return nil
+}
+
+// ShuttingDown reports whether Drain or ShutDown has been called
+func (jm *JobManager) ShuttingDown() bool {
+	jm.runMu.Lock()
+	defer jm.runMu.Unlock()
+	return jm.closing
+}
+
+// ShutDown stops the scheduler and waits for the runs in progress to finish until ctx
+// is done. The runs still going then are cancelled, which records them as cancelled,
+// and are given a short grace period to do so. The executors are closed last. The store
+// must stay open until it returns
+func (jm *JobManager) ShutDown(ctx context.Context) error {
+	defer jm.closeExecutors()
+
+	jm.Drain()
+
+	jm.mu.Lock()
+	jm.scheduling = false
+	jm.mu.Unlock()
+	cronDone := jm.cron.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		jm.runs.Wait()
+		<-cronDone.Done()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("[JOB] All runs finished")
+		return nil
+	case <-ctx.Done():
+	}
+
+	log.Printf("[WARN] Shutdown deadline reached, cancelling the runs still in progress")
+	jm.stopRuns()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(cancelGrace):
+		return fmt.Errorf("cancelled runs did not finish within %s", cancelGrace)
+	}
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"time"
+
+	"schedulerservice/internal/metrics"
+)
+
+// Store persists job definitions, their runtime state, the execution history and the metrics
+type Store interface {
+	// LoadJobs returns every stored job along with its runtime state
+	LoadJobs() ([]StoredJob, error)
+	// InsertJob stores a new job definition
+	InsertJob(job Job) error
+	// UpdateJob replaces the stored definition of a job, failing with ErrJobNotFound if there is none
+	UpdateJob(job Job) error
+	// DeleteJob removes a job definition
+	DeleteJob(name string) error
+	// UpdateJobState stores the outcome of the latest run of a job
+	UpdateJobState(name string, state JobState) error
+	// SetJobLastRun stores when the latest scheduled run of a job started
+	SetJobLastRun(name string, lastRunAt time.Time) error
+	// ClaimRun stores scheduledAt as the latest scheduled run of a job unless a run at or after
+	// it is already stored. It reports whether the caller claimed the run, so that instances
+	// sharing the store make every scheduled run once
+	ClaimRun(name string, scheduledAt time.Time) (bool, error)
+	// SetJobCompleted marks a one-shot job as done
+	SetJobCompleted(name string, completedAt time.Time) error
+	// SetJobPaused stores the paused state of a job
+	SetJobPaused(name string, paused bool) error
+
+	// StartExecution stores a new execution and sets its ID
+	StartExecution(exec *Execution) error
+	// FinishExecution stores the final state of an execution started with StartExecution
+	FinishExecution(exec *Execution) error
+	// ListExecutions returns a page of the execution history of a job, newest first,
+	// along with the total number of executions matching the filter
+	ListExecutions(name string, filter ExecutionFilter) ([]Execution, int, error)
+
+	// LoadMetrics returns the stored metric values
+	LoadMetrics() ([]Metric, error)
+	// AddGlobalMetric adds value to a service-wide metric, one of GlobalMetrics
+	AddGlobalMetric(name metrics.MetricName, value float64) error
+	// AddJobMetric adds value to a metric of a single job
+	AddJobMetric(name metrics.MetricName, jobName string, value float64) error
+
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// StoredJob is a job definition as stored, along with its runtime state
+type StoredJob struct {
+	Job       Job
+	State     JobState
+	CreatedAt time.Time
+}
+
+// Metric is a stored metric value. JobName is empty for service-wide metrics
+type Metric struct {
+	Name    metrics.MetricName
+	JobName string
+	Value   float64
+}
+
+// GlobalMetrics lists the service-wide metrics kept with AddGlobalMetric. Stores provide
+// a row for each of them up front, and LoadMetricsFromDB reports the missing ones
+var GlobalMetrics = []metrics.MetricName{
+	metrics.TotalJobs,
+	metrics.ActiveJobs,
+	metrics.TotalExecutions,
+	metrics.ExecutionDuration,
+}
@@ -0,0 +1,146 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// KafkaTarget is the message produced on each run of a job with the kafka target. Key and
+// Payload are Go templates executed with a TemplateData, e.g. {"job":{{json .JobName}}}
+type KafkaTarget struct {
+	Topic string `json:"topic"`
+	// Key defaults to the job name, so the messages of a job keep their order
+	Key string `json:"key,omitempty"`
+	// Payload is a JSON value, unless it is a JSON string, whose contents are the payload.
+	// It defaults to the TemplateData as JSON
+	Payload json.RawMessage   `json:"payload,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// TemplateData describes the attempt a Kafka message is produced for
+type TemplateData struct {
+	JobName     string    `json:"job_name"`
+	ExecutionID int64     `json:"execution_id"`
+	Attempt     int       `json:"attempt"`
+	Trigger     Trigger   `json:"trigger"`
+	Time        time.Time `json:"time"`
+}
+
+// templateFuncs are the functions available to the Kafka templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value, e.g. to quote and escape a string
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// validateTarget checks that the job has the settings of its target, and only those
+func validateTarget(job *Job) error {
+	switch job.Target {
+	case "", TargetHTTP:
+		job.Target = TargetHTTP
+		if job.Kafka != nil {
+			return fmt.Errorf("kafka settings only apply to the kafka target")
+		}
+		return validateRequest(job)
+	case TargetKafka:
+		if job.Endpoint != "" || job.Method != "" || len(job.Headers) > 0 || len(job.Query) > 0 || (len(job.Body) > 0 && !isNull(job.Body)) {
+			return fmt.Errorf("endpoint, method, headers, query and body only apply to the http target")
+		}
+		return validateKafkaTarget(job.Kafka)
+	default:
+		return fmt.Errorf("unsupported target %q, must be http or kafka", job.Target)
+	}
+}
+
+// validateKafkaTarget checks the topic and templates of a kafka target
+func validateKafkaTarget(target *KafkaTarget) error {
+	if target == nil || strings.TrimSpace(target.Topic) == "" {
+		return fmt.Errorf("kafka target needs a topic")
+	}
+	if _, err := parseTemplate("key", target.Key); err != nil {
+		return err
+	}
+	payload, err := target.payloadText()
+	if err != nil {
+		return err
+	}
+	_, err = parseTemplate("payload", payload)
+	return err
+}
+
+// Render executes the key and payload templates for an attempt
+func (t *KafkaTarget) Render(exec Execution) (key, payload []byte, err error) {
+	data := TemplateData{
+		JobName:     exec.JobName,
+		ExecutionID: exec.ID,
+		Attempt:     exec.Attempt,
+		Trigger:     exec.Trigger,
+		Time:        exec.StartedAt,
+	}
+
+	key = []byte(data.JobName)
+	if t.Key != "" {
+		if key, err = executeTemplate("key", t.Key, data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	text, err := t.payloadText()
+	if err != nil {
+		return nil, nil, err
+	}
+	if text == "" {
+		payload, err = json.Marshal(data)
+	} else {
+		payload, err = executeTemplate("payload", text, data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, payload, nil
+}
+
+// payloadText returns the payload template: the contents of a JSON string, or the JSON text
+func (t *KafkaTarget) payloadText() (string, error) {
+	trimmed := bytes.TrimSpace(t.Payload)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return "", nil
+	}
+	if !json.Valid(trimmed) {
+		return "", fmt.Errorf("kafka payload must be valid JSON or a JSON string")
+	}
+	if trimmed[0] == '"' {
+		var text string
+		if err := json.Unmarshal(trimmed, &text); err != nil {
+			return "", fmt.Errorf("invalid kafka payload: %w", err)
+		}
+		return text, nil
+	}
+	return string(trimmed), nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func executeTemplate(name, text string, data TemplateData) ([]byte, error) {
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to render kafka %s: %w", name, err)
+	}
+	return out.Bytes(), nil
+}
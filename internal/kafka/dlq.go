@@ -3,7 +3,6 @@ package kafka
 import (
     "context"
     "log"
-    "os"
     "strconv"
     "time"
 
@@ -16,21 +15,13 @@ const errorHeader = "error-reason"
 
 // sendToDLQ writes the original message and an error reason to a DLQ topic.
 // It includes original payload and simple metadata in headers.
-func sendToDLQ(ctx context.Context, orig kafka.Message, reason string) error {
-    brokers := os.Getenv("KAFKA_BROKERS")
-    dlqTopic := os.Getenv("KAFKA_DLQ_TOPIC")
-    if dlqTopic == "" {
+func sendToDLQ(ctx context.Context, p *producer, orig kafka.Message, reason string) error {
+    if p.cfg.DLQTopic == "" {
         log.Printf("[DLQ] no DLQ topic configured; dropping message id/key=%s reason=%s", string(orig.Key), reason)
         return nil
     }
 
-    w := kafka.NewWriter(kafka.WriterConfig{
-        Brokers: []string{brokers},
-        Topic:   dlqTopic,
-    })
-    defer w.Close()
-
-    headers := append(orig.Headers,
+    headers := append(append([]kafka.Header(nil), orig.Headers...),
         kafka.Header{Key: errorHeader, Value: []byte(reason)},
         kafka.Header{Key: "dlq-timestamp", Value: []byte(strconv.FormatInt(time.Now().Unix(), 10))},
     )
@@ -41,7 +32,7 @@ func sendToDLQ(ctx context.Context, orig kafka.Message, reason string) error {
         Headers: headers,
     }
 
-    return w.WriteMessages(ctx, msg)
+    return p.write(ctx, p.cfg.DLQTopic, msg)
 }
 
 // getAttempts reads the attempts header from a kafka.Message.
@@ -58,34 +49,26 @@ func getAttempts(msg kafka.Message) int {
 
 // setAttempts sets/updates the attempts header.
 func setAttempts(msg *kafka.Message, attempts int) {
-    seen := false
-    for i := range msg.Headers {
-        if msg.Headers[i].Key == attemptsHeader {
-            msg.Headers[i].Value = []byte(strconv.Itoa(attempts))
-            seen = true
-            break
+    setHeader(msg, attemptsHeader, strconv.Itoa(attempts))
+}
+
+// getHeader returns the value of a header, or an empty string if it is missing.
+func getHeader(msg kafka.Message, key string) string {
+    for _, h := range msg.Headers {
+        if h.Key == key {
+            return string(h.Value)
         }
     }
-    if !seen {
-        msg.Headers = append(msg.Headers, kafka.Header{Key: attemptsHeader, Value: []byte(strconv.Itoa(attempts))})
-    }
+    return ""
 }
 
-// requeueMessage writes the message back to either the original topic or a retry topic.
-// You can add delay by sleeping or producing to time-partitioned retry topics.
-func requeueMessage(ctx context.Context, orig kafka.Message) error {
-    brokers := os.Getenv("KAFKA_BROKERS")
-		time.Sleep(10 * time.Second)
-    topic := os.Getenv("KAFKA_TOPIC")
-    if topic == "" {
-        topic = orig.Topic
+// setHeader sets/updates a header.
+func setHeader(msg *kafka.Message, key, value string) {
+    for i := range msg.Headers {
+        if msg.Headers[i].Key == key {
+            msg.Headers[i].Value = []byte(value)
+            return
+        }
     }
-
-    w := kafka.NewWriter(kafka.WriterConfig{
-        Brokers: []string{brokers},
-        Topic:   topic,
-    })
-    defer w.Close()
-
-    return w.WriteMessages(ctx, orig)
+    msg.Headers = append(msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
 }
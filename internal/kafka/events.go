@@ -0,0 +1,197 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/config"
+	"schedulerservice/internal/jobs"
+	"schedulerservice/internal/metrics"
+)
+
+const (
+	// eventVersion is the Version of the published KafkaEvent envelopes
+	eventVersion = 1
+	// eventBufferSize is the number of events waiting to be written before new ones are dropped
+	eventBufferSize = 1024
+	// eventBatchSize is the largest number of events written at once
+	eventBatchSize = 100
+)
+
+// Publisher publishes the events of the job manager to the events topic. Events are
+// buffered and written by a background goroutine, so Publish never blocks: events
+// published while the buffer is full are dropped
+type Publisher struct {
+	writer messageWriter
+	events chan jobs.Event
+	// ctx bounds the writes, it is cancelled once Close gives up waiting for them
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// mu guards closed, so no event is sent on events once it is closed
+	mu     sync.RWMutex
+	closed bool
+	// dropping is set while the buffer is full, so the drops are logged once
+	dropping atomic.Bool
+}
+
+// NewPublisher starts a publisher writing to the events topic of cfg. Without brokers or
+// an events topic, the publisher drops every event
+func NewPublisher(cfg config.Kafka) *Publisher {
+	if !cfg.Enabled() || cfg.EventsTopic == "" {
+		p := &Publisher{done: make(chan struct{}), closed: true}
+		close(p.done)
+		return p
+	}
+
+	p := newPublisher(&kafka.Writer{
+		Addr:  kafka.TCP(cfg.Brokers...),
+		Topic: cfg.EventsTopic,
+		// The events of a job are keyed by its name, so they keep their order
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	})
+	log.Printf("[KAFKA] Event publisher initialized")
+	return p
+}
+
+// newPublisher starts a publisher writing through writer
+func newPublisher(writer messageWriter) *Publisher {
+	p := &Publisher{
+		writer: writer,
+		events: make(chan jobs.Event, eventBufferSize),
+		done:   make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	go p.run()
+	return p
+}
+
+// Publish queues an event to be written, or drops it if the buffer is full
+func (p *Publisher) Publish(event jobs.Event) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+
+	select {
+	case p.events <- event:
+		p.dropping.Store(false)
+	default:
+		metrics.KafkaEventsDropped.Inc()
+		if !p.dropping.Swap(true) {
+			log.Printf("[WARN] Event buffer full, dropping events until it has room")
+		}
+	}
+}
+
+// Close stops taking events and waits until the buffered ones are written, or until ctx
+// is done, in which case the events left are lost
+func (p *Publisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.events)
+	p.mu.Unlock()
+
+	var err error
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		p.cancel()
+		<-p.done
+		err = fmt.Errorf("events still buffered were lost: %w", ctx.Err())
+	}
+	p.cancel()
+	if closeErr := p.writer.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// run writes the buffered events in batches until the buffer is closed and empty
+func (p *Publisher) run() {
+	defer close(p.done)
+	batch := make([]kafka.Message, 0, eventBatchSize)
+	for event := range p.events {
+		batch = p.add(batch[:0], event)
+		// Events published while the previous batch was written go in the same batch
+	fill:
+		for len(batch) < eventBatchSize {
+			select {
+			case event, ok := <-p.events:
+				if !ok {
+					break fill
+				}
+				batch = p.add(batch, event)
+			default:
+				break fill
+			}
+		}
+		p.write(batch)
+	}
+}
+
+// write sends a batch of events, which are lost if the write fails
+func (p *Publisher) write(batch []kafka.Message) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, writeTimeout)
+	defer cancel()
+	if err := p.writer.WriteMessages(ctx, batch...); err != nil {
+		log.Printf("[ERROR] Failed to publish %d event(s): %v", len(batch), err)
+	}
+}
+
+// add appends an event to batch, wrapped in a KafkaEvent envelope keyed by the job name.
+// An event that cannot be encoded is left out
+func (p *Publisher) add(batch []kafka.Message, event jobs.Event) []kafka.Message {
+	var payload any
+	switch {
+	case event.Execution != nil:
+		payload = event.Execution
+	case event.Job != nil:
+		payload = event.Job
+	default:
+		payload = jobs.JobName{Name: event.JobName}
+	}
+
+	data, err := json.Marshal(payload)
+	if err == nil {
+		data, err = json.Marshal(KafkaEvent{
+			Id:        newEventID(),
+			Type:      string(event.Type),
+			Version:   eventVersion,
+			Payload:   data,
+			Timestamp: event.Time.Unix(),
+		})
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode %s event of job %s: %v", event.Type, event.JobName, err)
+		return batch
+	}
+	return append(batch, kafka.Message{Key: []byte(event.JobName), Value: data})
+}
+
+// newEventID returns a random ID for an event
+func newEventID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
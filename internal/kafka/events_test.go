@@ -0,0 +1,168 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/config"
+	"schedulerservice/internal/jobs"
+)
+
+// gatedWriter holds every write until release is closed, or until the write is cancelled.
+// started receives a value as each write comes in
+type gatedWriter struct {
+	*fakeWriter
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{fakeWriter: &fakeWriter{}, started: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (w *gatedWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.started <- struct{}{}
+	select {
+	case <-w.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return w.fakeWriter.WriteMessages(ctx, msgs...)
+}
+
+// closePublisher closes p, failing the test if the buffered events are not written in time
+func closePublisher(t *testing.T, p *Publisher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestPublisherEnvelope(t *testing.T) {
+	at := time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC)
+	job := jobs.Job{Name: "ping", Cron: "*/10 * * * * *", Endpoint: "http://localhost:3000/ping"}
+	exec := jobs.Execution{ID: 7, JobName: "ping", Attempt: 1, Status: jobs.ExecutionSucceeded, StartedAt: at}
+
+	tests := []struct {
+		name    string
+		event   jobs.Event
+		payload string
+	}{
+		{
+			name:    "job event",
+			event:   jobs.Event{Type: jobs.EventJobRegistered, JobName: "ping", Job: &job, Time: at},
+			payload: `"cron":"*/10 * * * * *"`,
+		},
+		{
+			name:    "execution event",
+			event:   jobs.Event{Type: jobs.EventExecutionSucceeded, JobName: "ping", Execution: &exec, Time: at},
+			payload: `"status":"succeeded"`,
+		},
+		{
+			name:    "deregistration",
+			event:   jobs.Event{Type: jobs.EventJobDeregistered, JobName: "ping", Time: at},
+			payload: `{"name":"ping"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &fakeWriter{}
+			p := newPublisher(w)
+			p.Publish(tt.event)
+			closePublisher(t, p)
+
+			written := w.written()
+			if len(written) != 1 {
+				t.Fatalf("publisher wrote %d message(s), want 1", len(written))
+			}
+			if string(written[0].Key) != "ping" {
+				t.Errorf("event is keyed by %q, want the job name", written[0].Key)
+			}
+			var e KafkaEvent
+			if err := json.Unmarshal(written[0].Value, &e); err != nil {
+				t.Fatalf("invalid event %s: %v", written[0].Value, err)
+			}
+			if e.Id == "" || e.Type != string(tt.event.Type) || e.Version != eventVersion || e.Timestamp != at.Unix() {
+				t.Errorf("event envelope is %+v, want an ID, type %s, version %d and timestamp %d", e, tt.event.Type, eventVersion, at.Unix())
+			}
+			if !json.Valid(e.Payload) || !strings.Contains(string(e.Payload), tt.payload) {
+				t.Errorf("event payload is %s, want it to contain %s", e.Payload, tt.payload)
+			}
+			if !w.closed {
+				t.Errorf("Close did not close the writer")
+			}
+		})
+	}
+}
+
+func TestPublisherBatchesEventsPublishedDuringAWrite(t *testing.T) {
+	w := newGatedWriter()
+	p := newPublisher(w)
+
+	p.Publish(jobs.Event{Type: jobs.EventJobUpdated, JobName: "first"})
+	<-w.started
+	// These wait for the first write and are then written together
+	for i := 0; i < 5; i++ {
+		p.Publish(jobs.Event{Type: jobs.EventJobUpdated, JobName: "next"})
+	}
+	close(w.release)
+	closePublisher(t, p)
+
+	if got := len(w.started); got != 1 {
+		t.Errorf("publisher wrote %d more batch(es) after the first, want the 5 events in one", got)
+	}
+	if written := w.written(); len(written) != 6 {
+		t.Errorf("publisher wrote %d event(s), want 6", len(written))
+	}
+}
+
+func TestPublisherCloseGivesUp(t *testing.T) {
+	w := newGatedWriter()
+	p := newPublisher(w)
+	p.Publish(jobs.Event{Type: jobs.EventJobUpdated, JobName: "ping"})
+	<-w.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close returned %v, want the deadline to be exceeded", err)
+	}
+	if written := w.written(); len(written) != 0 {
+		t.Errorf("publisher wrote %v after Close gave up", written)
+	}
+}
+
+func TestPublisherDropsEvents(t *testing.T) {
+	t.Run("after Close", func(t *testing.T) {
+		w := &fakeWriter{}
+		p := newPublisher(w)
+		closePublisher(t, p)
+		p.Publish(jobs.Event{Type: jobs.EventJobUpdated, JobName: "ping"})
+		closePublisher(t, p)
+		if written := w.written(); len(written) != 0 {
+			t.Errorf("publisher wrote %v after it was closed", written)
+		}
+	})
+
+	t.Run("while disabled", func(t *testing.T) {
+		cfgs := map[string]config.Kafka{
+			"no brokers":      {EventsTopic: "job-changes"},
+			"no events topic": {Brokers: []string{"localhost:9092"}},
+		}
+		for name, cfg := range cfgs {
+			p := NewPublisher(cfg)
+			p.Publish(jobs.Event{Type: jobs.EventJobUpdated, JobName: "ping"})
+			if err := p.Close(context.Background()); err != nil {
+				t.Errorf("%s: Close: %v", name, err)
+			}
+		}
+	})
+}
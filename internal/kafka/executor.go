@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/config"
+	"schedulerservice/internal/jobs"
+	"schedulerservice/internal/metrics"
+)
+
+// producedResult is the result label of a message produced by a job
+const producedResult = "produced"
+
+// Executor produces the messages of the jobs with the kafka target. A run succeeds once
+// every in-sync replica of the partition has the message
+type Executor struct {
+	writer messageWriter
+}
+
+// NewExecutor creates an executor producing to the brokers of cfg, through a single
+// writer which picks the topic of each message
+func NewExecutor(cfg config.Kafka) *Executor {
+	return &Executor{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+// Execute renders and produces the message of an attempt. The result body tells the
+// topic and key of the produced message
+func (e *Executor) Execute(ctx context.Context, job jobs.Job, exec jobs.Execution) (jobs.Result, error) {
+	target := job.Kafka
+	key, payload, err := target.Render(exec)
+	if err != nil {
+		metrics.JobKafkaMessages.WithLabelValues(job.Name, target.Topic, string(jobs.ErrorRequest)).Inc()
+		return jobs.Result{Kind: jobs.ErrorRequest}, err
+	}
+
+	msg := kafka.Message{Topic: target.Topic, Key: key, Value: payload}
+	for name, value := range target.Headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
+
+	if err := e.writer.WriteMessages(ctx, msg); err != nil {
+		err = writeError(err)
+		kind := classifyWriteError(err)
+		metrics.JobKafkaMessages.WithLabelValues(job.Name, target.Topic, string(kind)).Inc()
+		return jobs.Result{Kind: kind}, fmt.Errorf("failed to produce to %s: %w", target.Topic, err)
+	}
+	metrics.JobKafkaMessages.WithLabelValues(job.Name, target.Topic, producedResult).Inc()
+	return jobs.Result{Body: fmt.Sprintf("produced to %s with key %q", target.Topic, key)}, nil
+}
+
+// Close flushes and closes the writer
+func (e *Executor) Close() error {
+	return e.writer.Close()
+}
+
+// writeError returns the error of the single message of a write
+func writeError(err error) error {
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		for _, writeErr := range writeErrs {
+			if writeErr != nil {
+				return writeErr
+			}
+		}
+	}
+	return err
+}
+
+// classifyWriteError returns the kind of a failed write, for the retry policy of the job
+func classifyWriteError(err error) jobs.ErrorKind {
+	var (
+		netErr    net.Error
+		brokerErr kafka.Error
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return jobs.ErrorTimeout
+	case errors.As(err, &brokerErr):
+		return jobs.ErrorBroker
+	case errors.As(err, &netErr):
+		return jobs.ErrorConnection
+	default:
+		return jobs.ErrorRequest
+	}
+}
@@ -0,0 +1,187 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/jobs"
+)
+
+// kafkaJob returns a job producing to the pings topic
+func kafkaJob(target jobs.KafkaTarget) jobs.Job {
+	if target.Topic == "" {
+		target.Topic = "pings"
+	}
+	return jobs.Job{Name: "ping", Cron: "0 0 1 1 *", Target: jobs.TargetKafka, Kafka: &target}
+}
+
+func TestExecutorExecute(t *testing.T) {
+	started := time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC)
+	exec := jobs.Execution{ID: 42, JobName: "ping", Attempt: 2, Trigger: jobs.TriggerManual, StartedAt: started}
+
+	tests := []struct {
+		name    string
+		target  jobs.KafkaTarget
+		key     string
+		payload string
+		headers map[string]string
+	}{
+		{
+			name:    "defaults",
+			key:     "ping",
+			payload: `{"job_name":"ping","execution_id":42,"attempt":2,"trigger":"manual","time":"2026-03-01T06:00:00Z"}`,
+		},
+		{
+			name: "templates",
+			target: jobs.KafkaTarget{
+				Key:     "{{.JobName}}-{{.ExecutionID}}",
+				Payload: json.RawMessage(`"{\"job\":{{json .JobName}},\"attempt\":{{.Attempt}},\"trigger\":{{json .Trigger}}}"`),
+			},
+			key:     "ping-42",
+			payload: `{"job":"ping","attempt":2,"trigger":"manual"}`,
+		},
+		{
+			name:    "raw payload",
+			target:  jobs.KafkaTarget{Payload: json.RawMessage(`"ping {{.Attempt}}"`)},
+			key:     "ping",
+			payload: "ping 2",
+		},
+		{
+			name:    "headers",
+			target:  jobs.KafkaTarget{Payload: json.RawMessage(`{}`), Headers: map[string]string{"source": "scheduler", "token": "secret"}},
+			key:     "ping",
+			payload: `{}`,
+			headers: map[string]string{"source": "scheduler", "token": "secret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &fakeWriter{}
+			e := &Executor{writer: w}
+
+			result, err := e.Execute(context.Background(), kafkaJob(tt.target), exec)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if !strings.Contains(result.Body, "pings") || result.Kind != "" {
+				t.Errorf("Execute returned %+v, want a body naming the topic and no error kind", result)
+			}
+
+			written := w.written()
+			if len(written) != 1 {
+				t.Fatalf("Execute wrote %d message(s), want 1", len(written))
+			}
+			msg := written[0]
+			if msg.Topic != "pings" || string(msg.Key) != tt.key || string(msg.Value) != tt.payload {
+				t.Errorf("Execute produced %s/%s: %s, want pings/%s: %s", msg.Topic, msg.Key, msg.Value, tt.key, tt.payload)
+			}
+			headers := make(map[string]string, len(msg.Headers))
+			for _, h := range msg.Headers {
+				headers[h.Key] = string(h.Value)
+			}
+			if len(headers) != len(tt.headers) {
+				t.Errorf("message has headers %v, want %v", headers, tt.headers)
+			}
+			for name, value := range tt.headers {
+				if headers[name] != value {
+					t.Errorf("message has header %s=%q, want %q", name, headers[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutorExecuteFails(t *testing.T) {
+	exec := jobs.Execution{ID: 1, JobName: "ping", Attempt: 1, Trigger: jobs.TriggerScheduled, StartedAt: time.Now()}
+
+	t.Run("render", func(t *testing.T) {
+		w := &fakeWriter{}
+		e := &Executor{writer: w}
+		result, err := e.Execute(context.Background(), kafkaJob(jobs.KafkaTarget{Key: "{{.Missing}}"}), exec)
+		if err == nil || result.Kind != jobs.ErrorRequest {
+			t.Errorf("Execute returned %+v, %v, want a request error", result, err)
+		}
+		if written := w.written(); len(written) != 0 {
+			t.Errorf("Execute wrote %v although the key could not be rendered", written)
+		}
+	})
+
+	t.Run("write", func(t *testing.T) {
+		w := &fakeWriter{failTopics: map[string]bool{"pings": true}}
+		e := &Executor{writer: w}
+		result, err := e.Execute(context.Background(), kafkaJob(jobs.KafkaTarget{}), exec)
+		if err == nil || !strings.Contains(err.Error(), "failed to produce to pings") || result.Kind != jobs.ErrorRequest {
+			t.Errorf("Execute returned %+v, %v, want the failed write", result, err)
+		}
+	})
+}
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyWriteError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name string
+		err  error
+		want jobs.ErrorKind
+	}{
+		{name: "deadline", err: fmt.Errorf("write: %w", context.DeadlineExceeded), want: jobs.ErrorTimeout},
+		{name: "network timeout", err: &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, want: jobs.ErrorTimeout},
+		{name: "broker", err: kafka.NotEnoughReplicas, want: jobs.ErrorBroker},
+		{name: "broker error of the message", err: writeError(kafka.WriteErrors{kafka.UnknownTopicOrPartition}), want: jobs.ErrorBroker},
+		{name: "connection", err: refused, want: jobs.ErrorConnection},
+		{name: "other", err: errors.New("message too large"), want: jobs.ErrorRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyWriteError(tt.err); got != tt.want {
+				t.Errorf("classifyWriteError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutorRegistration(t *testing.T) {
+	jm := newTestManager(t)
+	job := kafkaJob(jobs.KafkaTarget{Payload: json.RawMessage(`"{\"job\":{{json .JobName}}}"`)})
+	job.Name = "produce"
+
+	// Without brokers no executor is set, and kafka jobs are refused
+	if _, err := jm.Register(job); err == nil || !strings.Contains(err.Error(), `target "kafka" is not available`) {
+		t.Fatalf("Register without a kafka executor returned %v, want it refused", err)
+	}
+
+	w := &fakeWriter{}
+	jm.SetExecutor(jobs.TargetKafka, &Executor{writer: w})
+	if _, err := jm.Register(job); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := jm.Trigger("produce"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(w.written()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the message of the run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if msg := w.written()[0]; msg.Topic != "pings" || string(msg.Value) != `{"job":"produce"}` {
+		t.Errorf("run produced %s: %s, want pings: {\"job\":\"produce\"}", msg.Topic, msg.Value)
+	}
+}
@@ -3,76 +3,160 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 
 	kafka "github.com/segmentio/kafka-go"
 
+	"schedulerservice/internal/config"
 	"schedulerservice/internal/jobs"
+	"schedulerservice/internal/metrics"
 )
 
-// KafkaInit initializes the Kafka consumer and starts processing messages.
-func InitKafka(ctx context.Context, jr jobs.JobRegistrar) {
-	brokers := os.Getenv("KAFKA_BROKERS")
-	topic := os.Getenv("KAFKA_TOPIC")
-	groupID := os.Getenv("KAFKA_GROUP_ID")
-	if brokers == "" || topic == "" || groupID == "" {
-		fmt.Println("KAFKA_BROKERS, KAFKA_TOPIC, and KAFKA_GROUP_ID environment variables must be set")
+// KafkaInit initializes the Kafka consumer and processes messages until ctx is cancelled.
+// Messages that fail are retried through the retry topics, consumed alongside the main topic,
+// and the result of every message is published to the reply topic if one is configured.
+func InitKafka(ctx context.Context, cfg config.Kafka, jr jobs.JobRegistrar) {
+	if !cfg.ConsumerEnabled() {
+		log.Printf("[KAFKA] No brokers or topic configured, the consumer is disabled")
 		return
 	}
 
+	p := newProducer(cfg)
+	defer p.Close()
+
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{brokers},
-		Topic:   topic,
-		GroupID: groupID,
+		Brokers: cfg.Brokers,
+		Topic:   cfg.Topic,
+		GroupID: cfg.GroupID,
 	})
 	defer reader.Close()
-	fmt.Println("Kafka consumer initialized")
+	log.Printf("[KAFKA] Consumer initialized")
 
-	for {
-		m, err := reader.ReadMessage(context.Background())
-		if err != nil {
-			fmt.Println("Error reading message:", err)
-			continue
-		}
-		if err := ProcessMessage(m, jr); err != nil {
-			attempts := getAttempts(m)
-			if attempts < maxRetries {
-				setAttempts(&m, attempts+1)
-				_ = requeueMessage(ctx, m)
-			} else {
-				_ = sendToDLQ(ctx, m, err.Error())
-			}
-		}
-	}
+	retriesDone := make(chan struct{})
+	go func() {
+		defer close(retriesDone)
+		consumeRetries(ctx, cfg, p, jr)
+	}()
+
+	consume(ctx, reader, func(m kafka.Message) error {
+		return p.handle(ctx, m, jr)
+	})
+	<-retriesDone
+	log.Printf("[KAFKA] Consumer stopped")
 }
 
+// errInvalidMessage matches the errors of messages that cannot be decoded or have an
+// unknown type, which no retry can fix
+var errInvalidMessage = errors.New("invalid message")
+
+// Outcomes of a processed message, counted in kafka_messages_processed_total
+const (
+	outcomeSucceeded      = "succeeded"
+	outcomeTransientError = "transient_error"
+	outcomePermanentError = "permanent_error"
+)
+
 // ProcessMessage processes a single Kafka message and performs the corresponding job operation.
+// Errors for which isPermanent is true will fail again however often the message is retried.
 func ProcessMessage(msg kafka.Message, jr jobs.JobRegistrar) error {
+	_, _, err := processMessage(msg, jr)
+	return err
+}
+
+// processMessage decodes and processes a message, counting its outcome. It returns the
+// decoded message and the name of the job it targets, as far as they could be decoded
+func processMessage(msg kafka.Message, jr jobs.JobRegistrar) (KafkaMessage, string, error) {
 	var km KafkaMessage
 	if err := json.Unmarshal(msg.Value, &km); err != nil {
-		log.Printf("[ERROR] invalid kafka message JSON: %v", err)
-		return err
+		err = fmt.Errorf("%w: invalid JSON: %w", errInvalidMessage, err)
+		countMessage("", err)
+		return km, "", err
 	}
 
+	jobName, err := processCommand(km, jr)
+	countMessage(km.Type, err)
+	return km, jobName, err
+}
+
+// processCommand performs the job operation of a decoded message and returns the name
+// of the job it targets
+func processCommand(km KafkaMessage, jr jobs.JobRegistrar) (string, error) {
 	switch km.Type {
 	case "REGISTER":
 		var job jobs.Job
-		if err := json.Unmarshal(km.Payload, &job); err != nil {
-			log.Printf("[ERROR] invalid job JSON: %v", err)
-			return err
+		if err := decodePayload(km, &job); err != nil {
+			return "", err
+		}
+		_, err := jr.Register(job)
+		return job.Name, err
+	case "UPDATE":
+		job, err := jobs.DecodeUpdate(km.Payload)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid %s payload: %w", errInvalidMessage, km.Type, err)
 		}
-		jr.Register(job)
+		_, err = jr.Update(job)
+		return job.Name, err
 	case "UNREGISTER":
 		var name jobs.JobName
-		if err := json.Unmarshal(km.Payload, &name); err != nil {
-			log.Printf("[ERROR] invalid job name JSON: %v", err)
-			return err
+		if err := decodePayload(km, &name); err != nil {
+			return "", err
+		}
+		return name.Name, jr.Deregister(name.Name)
+	case "TRIGGER":
+		var name jobs.JobName
+		if err := decodePayload(km, &name); err != nil {
+			return "", err
+		}
+		return name.Name, jr.Trigger(name.Name)
+	case "PAUSE":
+		var name jobs.JobName
+		if err := decodePayload(km, &name); err != nil {
+			return "", err
+		}
+		return name.Name, jr.Pause(name.Name)
+	case "RESUME":
+		var name jobs.JobName
+		if err := decodePayload(km, &name); err != nil {
+			return "", err
 		}
-		jr.Deregister(name.Name)
+		return name.Name, jr.Resume(name.Name)
 	default:
-		log.Printf("[WARN] unknown kafka message type: %s", km.Type)
+		return "", fmt.Errorf("%w: unknown type %q", errInvalidMessage, km.Type)
+	}
+}
+
+// decodePayload decodes the payload of a message into v
+func decodePayload(km KafkaMessage, v any) error {
+	if err := json.Unmarshal(km.Payload, v); err != nil {
+		return fmt.Errorf("%w: invalid %s payload: %w", errInvalidMessage, km.Type, err)
 	}
 	return nil
 }
+
+// isPermanent reports whether processing a message failed in a way no retry can fix,
+// such as a malformed message, an invalid job or an unknown job name
+func isPermanent(err error) bool {
+	return errors.Is(err, errInvalidMessage) || jobs.IsPermanent(err)
+}
+
+// countMessage counts a processed message by type and outcome. Unknown types are counted
+// as "unknown" to keep the number of series bounded
+func countMessage(messageType string, err error) {
+	switch messageType {
+	case "REGISTER", "UPDATE", "UNREGISTER", "TRIGGER", "PAUSE", "RESUME":
+	default:
+		messageType = "unknown"
+	}
+
+	outcome := outcomeSucceeded
+	switch {
+	case err == nil:
+	case isPermanent(err):
+		outcome = outcomePermanentError
+	default:
+		outcome = outcomeTransientError
+	}
+	metrics.KafkaMessages.WithLabelValues(messageType, outcome).Inc()
+}
@@ -0,0 +1,230 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/jobs"
+	"schedulerservice/internal/metrics"
+)
+
+// newTestManager creates a job manager kept in memory, with a job named ping that never
+// runs on its own, shut down at the end of the test
+func newTestManager(t *testing.T) *jobs.JobManager {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	jm := jobs.NewJobManager(jobs.NewMemoryStore())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		jm.ShutDown(ctx)
+	})
+	if _, err := jm.Register(jobs.Job{Name: "ping", Cron: "0 0 1 1 *", Endpoint: server.URL}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return jm
+}
+
+// command returns a message on the main topic of the given type and payload
+func command(messageType, payload string) kafka.Message {
+	value, _ := json.Marshal(KafkaMessage{Id: "msg-1", Type: messageType, Payload: json.RawMessage(payload)})
+	return kafka.Message{Topic: "job-events", Key: []byte("key-1"), Value: value}
+}
+
+// processed returns the count of kafka_messages_processed_total for a type and outcome
+func processed(t *testing.T, messageType, outcome string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.KafkaMessages.WithLabelValues(messageType, outcome).Write(&m); err != nil {
+		t.Fatalf("failed to read %s: %v", metrics.KafkaMessagesProcessed, err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestProcessMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       kafka.Message
+		jobName   string
+		outcome   string
+		permanent bool
+		// countedAs is the type the message is counted under, if not its own
+		countedAs string
+		check     func(t *testing.T, jm *jobs.JobManager)
+	}{
+		{
+			name:    "register",
+			msg:     command("REGISTER", `{"name":"report","cron":"0 6 * * *","endpoint":"http://localhost:3000/report"}`),
+			jobName: "report", outcome: outcomeSucceeded,
+			check: func(t *testing.T, jm *jobs.JobManager) {
+				if _, err := jm.Get("report"); err != nil {
+					t.Errorf("registered job: %v", err)
+				}
+			},
+		},
+		{
+			name:    "update",
+			msg:     command("UPDATE", `{"name":"ping","cron":"0 7 * * *","headers":null}`),
+			jobName: "ping", outcome: outcomeSucceeded,
+			check: func(t *testing.T, jm *jobs.JobManager) {
+				if job, _ := jm.Get("ping"); job.Cron != "0 7 * * *" {
+					t.Errorf("updated job has cron %q, want 0 7 * * *", job.Cron)
+				}
+			},
+		},
+		{
+			name:    "pause",
+			msg:     command("PAUSE", `{"name":"ping"}`),
+			jobName: "ping", outcome: outcomeSucceeded,
+			check: func(t *testing.T, jm *jobs.JobManager) {
+				if job, _ := jm.Get("ping"); !job.Paused {
+					t.Errorf("job is not paused")
+				}
+			},
+		},
+		{name: "resume", msg: command("RESUME", `{"name":"ping"}`), jobName: "ping", outcome: outcomeSucceeded},
+		{name: "trigger", msg: command("TRIGGER", `{"name":"ping"}`), jobName: "ping", outcome: outcomeSucceeded},
+		{
+			name:    "unregister",
+			msg:     command("UNREGISTER", `{"name":"ping"}`),
+			jobName: "ping", outcome: outcomeSucceeded,
+			check: func(t *testing.T, jm *jobs.JobManager) {
+				if _, err := jm.Get("ping"); !errors.Is(err, jobs.ErrJobNotFound) {
+					t.Errorf("unregistered job is still there: %v", err)
+				}
+			},
+		},
+		{
+			name:    "invalid JSON",
+			msg:     kafka.Message{Value: []byte(`{"type":`)},
+			outcome: outcomePermanentError, permanent: true, countedAs: "unknown",
+		},
+		{name: "unknown type", msg: command("DELETE", `{"name":"ping"}`), outcome: outcomePermanentError, permanent: true, countedAs: "unknown"},
+		{name: "invalid payload", msg: command("TRIGGER", `["ping"]`), outcome: outcomePermanentError, permanent: true},
+		{name: "invalid update payload", msg: command("UPDATE", `"ping"`), outcome: outcomePermanentError, permanent: true},
+		{
+			name:    "invalid job",
+			msg:     command("REGISTER", `{"name":"report","cron":"every day","endpoint":"http://localhost:3000/report"}`),
+			jobName: "report", outcome: outcomePermanentError, permanent: true,
+		},
+		{
+			name:    "existing job",
+			msg:     command("REGISTER", `{"name":"ping","cron":"0 6 * * *","endpoint":"http://localhost:3000/ping"}`),
+			jobName: "ping", outcome: outcomePermanentError, permanent: true,
+		},
+		{name: "unknown job", msg: command("TRIGGER", `{"name":"missing"}`), jobName: "missing", outcome: outcomePermanentError, permanent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jm := newTestManager(t)
+			var km KafkaMessage
+			json.Unmarshal(tt.msg.Value, &km)
+			countType := km.Type
+			if tt.countedAs != "" {
+				countType = tt.countedAs
+			}
+			before := processed(t, countType, tt.outcome)
+
+			_, jobName, err := processMessage(tt.msg, jm)
+			if (err != nil) != (tt.outcome != outcomeSucceeded) {
+				t.Fatalf("processMessage returned %v, want outcome %s", err, tt.outcome)
+			}
+			if isPermanent(err) != tt.permanent {
+				t.Errorf("isPermanent(%v) = %v, want %v", err, isPermanent(err), tt.permanent)
+			}
+			if jobName != tt.jobName {
+				t.Errorf("processMessage targeted job %q, want %q", jobName, tt.jobName)
+			}
+			if got := processed(t, countType, tt.outcome) - before; got != 1 {
+				t.Errorf("%s messages counted as %s %v times, want once", countType, tt.outcome, got)
+			}
+			if tt.check != nil {
+				tt.check(t, jm)
+			}
+		})
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "invalid message", err: fmt.Errorf("%w: unknown type %q", errInvalidMessage, "DELETE"), want: true},
+		{name: "invalid job", err: fmt.Errorf("invalid cron: %w", jobs.ErrInvalidJob), want: true},
+		{name: "unknown job", err: fmt.Errorf("job %q %w", "ping", jobs.ErrJobNotFound), want: true},
+		{name: "existing job", err: fmt.Errorf("job %q %w", "ping", jobs.ErrJobExists), want: true},
+		{name: "shutting down", err: fmt.Errorf("cannot trigger job %q: %w", "ping", jobs.ErrShuttingDown)},
+		{name: "failing store", err: errors.New("database is locked")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanent(tt.err); got != tt.want {
+				t.Errorf("isPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    kafka.Message
+		drain  bool
+		topics []string
+	}{
+		{name: "success is replied to", msg: command("PAUSE", `{"name":"ping"}`), topics: []string{"job-events-replies"}},
+		{name: "transient failure is retried", msg: command("PAUSE", `{"name":"ping"}`), drain: true, topics: []string{"job-events.retry.10s"}},
+		{
+			name:   "permanent failure is dead-lettered and replied to",
+			msg:    command("PAUSE", `{"name":"missing"}`),
+			topics: []string{"job-events-dlq", "job-events-replies"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jm := newTestManager(t)
+			if tt.drain {
+				jm.Drain()
+			}
+			p, w := newTestProducer(testKafkaConfig())
+
+			if err := p.handle(context.Background(), tt.msg, jm); err != nil {
+				t.Fatalf("handle: %v", err)
+			}
+			written := w.written()
+			if len(written) != len(tt.topics) {
+				t.Fatalf("handle wrote %d message(s), want %d to %v", len(written), len(tt.topics), tt.topics)
+			}
+			for i, msg := range written {
+				if msg.Topic != tt.topics[i] {
+					t.Errorf("message %d was written to %s, want %s", i, msg.Topic, tt.topics[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleFailsWhenNothingCanBeWritten(t *testing.T) {
+	jm := newTestManager(t)
+	p, w := newTestProducer(testKafkaConfig())
+	w.failTopics["job-events-dlq"] = true
+	w.failTopics["job-events-replies"] = true
+
+	// The message is left uncommitted, and so consumed again, rather than lost
+	if err := p.handle(context.Background(), command("PAUSE", `{"name":"missing"}`), jm); err == nil {
+		t.Errorf("handle succeeded although the failed message could not be written to the DLQ")
+	}
+}
@@ -2,6 +2,8 @@ package kafka
 
 import (
 	"encoding/json"
+
+	"schedulerservice/internal/jobs"
 )
 
 type KafkaMessage struct {
@@ -11,3 +13,23 @@ type KafkaMessage struct {
 	Timestamp 	int64  `json:"timestamp"`
 }
 
+
+// KafkaReply is the result of a KafkaMessage, published to the reply topic
+type KafkaReply struct {
+	Id        string            `json:"id"`
+	Type      string            `json:"type"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Job       *jobs.JobListItem `json:"job,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// KafkaEvent is the envelope of a job or execution event published to the events topic.
+// Version changes when the payload of an event type changes incompatibly
+type KafkaEvent struct {
+	Id        string          `json:"id"`
+	Type      string          `json:"type"`
+	Version   int             `json:"version"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp int64           `json:"timestamp"`
+}
@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/jobs"
+)
+
+// Statuses of a KafkaReply
+const (
+	replySucceeded = "succeeded"
+	replyFailed    = "failed"
+)
+
+// reply publishes the result of a message to the reply topic, keyed by the message ID or,
+// if the message has none, by its key. The reply carries the state of the job the message
+// targets, with its headers redacted, unless the job does not exist, e.g. once it is unregistered
+func (p *producer) reply(ctx context.Context, msg kafka.Message, km KafkaMessage, jobName string, procErr error, jr jobs.JobRegistrar) {
+	if p.cfg.ReplyTopic == "" {
+		return
+	}
+
+	r := KafkaReply{
+		Id:        km.Id,
+		Type:      km.Type,
+		Status:    replySucceeded,
+		Timestamp: time.Now().Unix(),
+	}
+	if procErr != nil {
+		r.Status = replyFailed
+		r.Error = procErr.Error()
+	}
+	if jobName != "" {
+		job, err := jr.Get(jobName)
+		switch {
+		case err == nil:
+			job.Job = job.Job.Redacted()
+			r.Job = &job
+		case !errors.Is(err, jobs.ErrJobNotFound):
+			log.Printf("[WARN] Failed to look up job %s for the reply to message key=%s: %v", jobName, string(msg.Key), err)
+		}
+	}
+
+	key := []byte(km.Id)
+	if km.Id == "" {
+		key = msg.Key
+	}
+	value, err := json.Marshal(r)
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode the reply to message key=%s: %v", string(msg.Key), err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	if err := p.write(ctx, p.cfg.ReplyTopic, kafka.Message{Key: key, Value: value}); err != nil {
+		log.Printf("[ERROR] Failed to publish the reply to message key=%s: %v", string(msg.Key), err)
+	}
+}
@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/jobs"
+)
+
+func TestReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		jobName string
+		err     error
+		key     string
+		status  string
+		withJob bool
+	}{
+		{name: "success", id: "msg-1", jobName: "ping", key: "msg-1", status: replySucceeded, withJob: true},
+		{
+			name: "failure", id: "msg-2", jobName: "ping", err: fmt.Errorf("job %q %w", "ping", jobs.ErrJobExists),
+			key: "msg-2", status: replyFailed, withJob: true,
+		},
+		{name: "keyed by the message key without an ID", jobName: "ping", key: "key-1", status: replySucceeded, withJob: true},
+		{name: "unknown job", id: "msg-3", jobName: "missing", key: "msg-3", status: replySucceeded},
+		{name: "undecodable message", err: errors.New("invalid message"), key: "key-1", status: replyFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jm := newTestManager(t)
+			if _, err := jm.Update(jobs.Job{Name: "ping", Headers: map[string]string{"Authorization": "Bearer secret"}}); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			p, w := newTestProducer(testKafkaConfig())
+			msg := kafka.Message{Key: []byte("key-1")}
+			km := KafkaMessage{Id: tt.id, Type: "PAUSE"}
+
+			p.reply(context.Background(), msg, km, tt.jobName, tt.err, jm)
+
+			written := w.written()
+			if len(written) != 1 || written[0].Topic != "job-events-replies" {
+				t.Fatalf("reply wrote %v, want one message to job-events-replies", written)
+			}
+			if string(written[0].Key) != tt.key {
+				t.Errorf("reply has key %q, want %q", written[0].Key, tt.key)
+			}
+			var r KafkaReply
+			if err := json.Unmarshal(written[0].Value, &r); err != nil {
+				t.Fatalf("invalid reply %s: %v", written[0].Value, err)
+			}
+			if r.Id != tt.id || r.Type != "PAUSE" || r.Status != tt.status || r.Timestamp == 0 {
+				t.Errorf("reply is %+v, want ID %q, type PAUSE and status %s", r, tt.id, tt.status)
+			}
+			if tt.err != nil && r.Error != tt.err.Error() {
+				t.Errorf("reply has error %q, want %q", r.Error, tt.err.Error())
+			}
+			if tt.err == nil && r.Error != "" {
+				t.Errorf("successful reply has error %q", r.Error)
+			}
+			if (r.Job != nil) != tt.withJob {
+				t.Fatalf("reply carries job %v, want one: %v", r.Job, tt.withJob)
+			}
+			if r.Job != nil {
+				if value, ok := r.Job.Headers["Authorization"]; !ok || value == "Bearer secret" {
+					t.Errorf("reply carries job headers %v, want the Authorization header redacted", r.Job.Headers)
+				}
+			}
+		})
+	}
+}
+
+func TestReplyWithoutTopic(t *testing.T) {
+	jm := newTestManager(t)
+	cfg := testKafkaConfig()
+	cfg.ReplyTopic = ""
+	p, w := newTestProducer(cfg)
+
+	p.reply(context.Background(), kafka.Message{}, KafkaMessage{Id: "msg-1", Type: "PAUSE"}, "ping", nil, jm)
+	if written := w.written(); len(written) != 0 {
+		t.Errorf("reply wrote %v without a reply topic", written)
+	}
+}
+
+func TestReplyWriteFails(t *testing.T) {
+	jm := newTestManager(t)
+	p, w := newTestProducer(testKafkaConfig())
+	w.failTopics["job-events-replies"] = true
+
+	// A lost reply does not fail the message, which has been processed already
+	if err := p.handle(context.Background(), command("PAUSE", `{"name":"ping"}`), jm); err != nil {
+		t.Errorf("handle failed because the reply could not be written: %v", err)
+	}
+	if job, _ := jm.Get("ping"); !job.Paused {
+		t.Errorf("job is not paused")
+	}
+}
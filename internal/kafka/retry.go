@@ -0,0 +1,248 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/config"
+	"schedulerservice/internal/jobs"
+)
+
+const (
+	// dueAtHeader holds when a retried message is due, in Unix milliseconds
+	dueAtHeader = "retry-due-at"
+	// originalTopicHeader holds the topic a retried message was first consumed from
+	originalTopicHeader = "original-topic"
+
+	// writeTimeout bounds the write of a failed message to a retry topic or the DLQ
+	writeTimeout = 10 * time.Second
+)
+
+// retryTier is a retry topic whose messages are processed once their delay has passed
+type retryTier struct {
+	topic string
+	delay time.Duration
+}
+
+// retryTiers returns the retry topics of cfg, named after the main topic and their
+// delay, e.g. job-events.retry.10s
+func retryTiers(cfg config.Kafka) []retryTier {
+	tiers := make([]retryTier, len(cfg.RetryDelays))
+	for i, delay := range cfg.RetryDelays {
+		tiers[i] = retryTier{topic: cfg.Topic + ".retry." + shortDuration(delay.Std()), delay: delay.Std()}
+	}
+	return tiers
+}
+
+// shortDuration formats d without its zero trailing units, e.g. 1m instead of 1m0s
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// messageWriter writes messages to Kafka, as *kafka.Writer does
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// producer writes retried and dead-lettered messages through a single long-lived writer,
+// which picks the topic of each message
+type producer struct {
+	cfg    config.Kafka
+	tiers  []retryTier
+	writer messageWriter
+}
+
+func newProducer(cfg config.Kafka) *producer {
+	return &producer{
+		cfg:   cfg,
+		tiers: retryTiers(cfg),
+		writer: &kafka.Writer{
+			Addr: kafka.TCP(cfg.Brokers...),
+			// Messages with the same key keep their order
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+// Close flushes and closes the writer
+func (p *producer) Close() error {
+	return p.writer.Close()
+}
+
+// handle processes a message and sends it on to a retry tier or the DLQ if it fails. Once
+// the message will not be retried, its result is published to the reply topic. It fails if
+// a failed message could be written to neither, so that the message is not committed
+func (p *producer) handle(ctx context.Context, msg kafka.Message, jr jobs.JobRegistrar) error {
+	km, jobName, err := processMessage(msg, jr)
+	if err != nil {
+		retried, failErr := p.handleFailure(ctx, msg, err)
+		if failErr != nil {
+			return failErr
+		}
+		if retried {
+			return nil
+		}
+	}
+	p.reply(ctx, msg, km, jobName, err, jr)
+	return nil
+}
+
+// handleFailure sends a message that could not be processed to the next retry tier,
+// counting the attempt in its attempts header, or to the DLQ once it is out of retries,
+// if its error is permanent or if the retry tier cannot be written to. It reports whether
+// the message was sent to a retry tier, and fails if the DLQ cannot be written to either.
+// The message is committed afterwards, so the write goes on while shutting down
+func (p *producer) handleFailure(ctx context.Context, msg kafka.Message, reason error) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+
+	attempts := getAttempts(msg)
+	permanent := isPermanent(reason)
+	switch {
+	case permanent:
+		log.Printf("[ERROR] Message key=%s cannot be processed (%v), sending it to the DLQ", string(msg.Key), reason)
+	case attempts >= maxRetries || len(p.tiers) == 0:
+		log.Printf("[ERROR] Message key=%s failed after %d attempt(s) (%v), sending it to the DLQ", string(msg.Key), attempts+1, reason)
+	default:
+		// The headers are copied, so the message sent to the DLQ if the write fails keeps its own
+		retried := msg
+		retried.Headers = append([]kafka.Header(nil), msg.Headers...)
+		setAttempts(&retried, attempts+1)
+		tier := p.tiers[min(attempts, len(p.tiers)-1)]
+		err := p.retry(ctx, retried, tier)
+		if err == nil {
+			log.Printf("[WARN] Message key=%s failed (%v), retrying in %s (attempt %d of %d)",
+				string(msg.Key), reason, tier.delay, attempts+1, maxRetries)
+			return true, nil
+		}
+		log.Printf("[ERROR] Failed to send message key=%s to retry topic %s (%v), sending it to the DLQ", string(msg.Key), tier.topic, err)
+	}
+
+	if err := sendToDLQ(ctx, p, msg, reason.Error()); err != nil {
+		return false, fmt.Errorf("failed to send message key=%s to the DLQ: %w", string(msg.Key), err)
+	}
+	return false, nil
+}
+
+// retry writes msg to a retry tier, due once the tier's delay has passed
+func (p *producer) retry(ctx context.Context, msg kafka.Message, tier retryTier) error {
+	if getHeader(msg, originalTopicHeader) == "" {
+		setHeader(&msg, originalTopicHeader, msg.Topic)
+	}
+	dueAt := time.Now().Add(tier.delay).UnixMilli()
+	setHeader(&msg, dueAtHeader, strconv.FormatInt(dueAt, 10))
+	return p.write(ctx, tier.topic, msg)
+}
+
+// write sends a copy of msg to topic. The offset and partition of the consumed message are dropped
+func (p *producer) write(ctx context.Context, topic string, msg kafka.Message) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: msg.Headers,
+	})
+}
+
+// consumeRetries runs a consumer per retry tier until ctx is cancelled
+func consumeRetries(ctx context.Context, cfg config.Kafka, p *producer, jr jobs.JobRegistrar) {
+	var wg sync.WaitGroup
+	for _, tier := range p.tiers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reader := kafka.NewReader(kafka.ReaderConfig{
+				Brokers: cfg.Brokers,
+				Topic:   tier.topic,
+				GroupID: cfg.GroupID,
+			})
+			defer reader.Close()
+
+			consume(ctx, reader, func(msg kafka.Message) error {
+				// Messages of a tier come due in the order they were written, so waiting
+				// for the first one holds back no message that is due sooner
+				if err := waitUntilDue(ctx, msg); err != nil {
+					return err
+				}
+				return p.handle(ctx, msg, jr)
+			})
+		}()
+	}
+	wg.Wait()
+}
+
+// handleRetryDelay is how long a message whose handling failed waits before it is handled again
+const handleRetryDelay = 5 * time.Second
+
+// consume hands every message of reader to handle and commits it once handled, until
+// ctx is cancelled. A message whose handling fails is handled again after handleRetryDelay,
+// holding back the messages behind it, and one whose handling is interrupted is left
+// uncommitted, so it is consumed again after a restart
+func consume(ctx context.Context, reader *kafka.Reader, handle func(kafka.Message) error) {
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to read message from %s: %v", reader.Config().Topic, err)
+			continue
+		}
+
+		for err := handle(msg); err != nil; err = handle(msg) {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[ERROR] Failed to handle message from %s at offset %d, handling it again in %s: %v",
+				msg.Topic, msg.Offset, handleRetryDelay, err)
+			select {
+			case <-time.After(handleRetryDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+		// The message is handled, so it is committed even while shutting down
+		if err := reader.CommitMessages(context.WithoutCancel(ctx), msg); err != nil {
+			log.Printf("[ERROR] Failed to commit message from %s: %v", msg.Topic, err)
+		}
+	}
+}
+
+// waitUntilDue blocks until the due time of a retried message, or until ctx is cancelled
+func waitUntilDue(ctx context.Context, msg kafka.Message) error {
+	dueAt, err := strconv.ParseInt(getHeader(msg, dueAtHeader), 10, 64)
+	if err != nil {
+		// Without a due time the message is processed right away
+		return nil
+	}
+
+	wait := time.Until(time.UnixMilli(dueAt))
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
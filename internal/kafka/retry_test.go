@@ -0,0 +1,267 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"schedulerservice/internal/config"
+	"schedulerservice/internal/jobs"
+)
+
+// fakeWriter records the messages written to it, with copies of their headers, and fails
+// the writes to the topics in failTopics
+type fakeWriter struct {
+	mu         sync.Mutex
+	messages   []kafka.Message
+	failTopics map[string]bool
+	closed     bool
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, msg := range msgs {
+		if w.failTopics[msg.Topic] {
+			return fmt.Errorf("write to %s failed", msg.Topic)
+		}
+	}
+	for _, msg := range msgs {
+		msg.Headers = append([]kafka.Header(nil), msg.Headers...)
+		w.messages = append(w.messages, msg)
+	}
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+// written returns the messages written so far
+func (w *fakeWriter) written() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.messages...)
+}
+
+// testKafkaConfig has three retry tiers, a DLQ and a reply topic
+func testKafkaConfig() config.Kafka {
+	return config.Kafka{
+		Brokers:     []string{"localhost:9092"},
+		Topic:       "job-events",
+		GroupID:     "schedulerservice",
+		DLQTopic:    "job-events-dlq",
+		ReplyTopic:  "job-events-replies",
+		RetryDelays: []config.Duration{config.Duration(10 * time.Second), config.Duration(time.Minute), config.Duration(10 * time.Minute)},
+	}
+}
+
+// newTestProducer creates a producer for cfg writing through a fakeWriter
+func newTestProducer(cfg config.Kafka) (*producer, *fakeWriter) {
+	w := &fakeWriter{failTopics: make(map[string]bool)}
+	return &producer{cfg: cfg, tiers: retryTiers(cfg), writer: w}, w
+}
+
+// withAttempts returns a message consumed from the main topic with the attempts header set
+func withAttempts(attempts int) kafka.Message {
+	msg := kafka.Message{Topic: "job-events", Key: []byte("ping"), Value: []byte(`{"type":"TRIGGER"}`)}
+	if attempts > 0 {
+		setAttempts(&msg, attempts)
+	}
+	return msg
+}
+
+func TestRetryTiers(t *testing.T) {
+	tiers := retryTiers(testKafkaConfig())
+	want := []string{"job-events.retry.10s", "job-events.retry.1m", "job-events.retry.10m"}
+	if len(tiers) != len(want) {
+		t.Fatalf("%d retry tiers, want %d", len(tiers), len(want))
+	}
+	for i, tier := range tiers {
+		if tier.topic != want[i] {
+			t.Errorf("tier %d is %s, want %s", i, tier.topic, want[i])
+		}
+	}
+}
+
+func TestHandleFailureTierSelection(t *testing.T) {
+	transient := errors.New("store unavailable")
+	permanent := fmt.Errorf("job %q %w", "ping", jobs.ErrJobNotFound)
+
+	tests := []struct {
+		name     string
+		noTiers  bool
+		attempts int
+		reason   error
+		topic    string
+		retried  bool
+	}{
+		{name: "first failure", attempts: 0, reason: transient, topic: "job-events.retry.10s", retried: true},
+		{name: "second failure", attempts: 1, reason: transient, topic: "job-events.retry.1m", retried: true},
+		{name: "third failure", attempts: 2, reason: transient, topic: "job-events.retry.10m", retried: true},
+		{name: "out of retries", attempts: maxRetries, reason: transient, topic: "job-events-dlq"},
+		{name: "permanent error", attempts: 0, reason: permanent, topic: "job-events-dlq"},
+		{name: "invalid message", attempts: 0, reason: fmt.Errorf("%w: invalid JSON", errInvalidMessage), topic: "job-events-dlq"},
+		{name: "retries turned off", noTiers: true, attempts: 0, reason: transient, topic: "job-events-dlq"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testKafkaConfig()
+			if tt.noTiers {
+				cfg.RetryDelays = nil
+			}
+			p, w := newTestProducer(cfg)
+
+			retried, err := p.handleFailure(context.Background(), withAttempts(tt.attempts), tt.reason)
+			if err != nil {
+				t.Fatalf("handleFailure: %v", err)
+			}
+			if retried != tt.retried {
+				t.Errorf("handleFailure reported retried=%v, want %v", retried, tt.retried)
+			}
+			written := w.written()
+			if len(written) != 1 || written[0].Topic != tt.topic {
+				t.Fatalf("handleFailure wrote %d message(s) %v, want one to %s", len(written), written, tt.topic)
+			}
+			msg := written[0]
+			if tt.retried {
+				if got := getAttempts(msg); got != tt.attempts+1 {
+					t.Errorf("retried message has attempts %d, want %d", got, tt.attempts+1)
+				}
+				if got := getHeader(msg, originalTopicHeader); got != "job-events" {
+					t.Errorf("retried message has original topic %q, want job-events", got)
+				}
+				if getHeader(msg, dueAtHeader) == "" {
+					t.Errorf("retried message has no due time")
+				}
+			} else if got := getHeader(msg, errorHeader); got != tt.reason.Error() {
+				t.Errorf("DLQ message has error reason %q, want %q", got, tt.reason.Error())
+			}
+		})
+	}
+}
+
+func TestHandleFailureRetryWriteFails(t *testing.T) {
+	p, w := newTestProducer(testKafkaConfig())
+	w.failTopics["job-events.retry.1m"] = true
+
+	msg := withAttempts(1)
+	retried, err := p.handleFailure(context.Background(), msg, errors.New("store unavailable"))
+	if err != nil {
+		t.Fatalf("handleFailure: %v", err)
+	}
+	if retried {
+		t.Errorf("handleFailure reported the message as retried although the retry write failed")
+	}
+
+	written := w.written()
+	if len(written) != 1 || written[0].Topic != "job-events-dlq" {
+		t.Fatalf("handleFailure wrote %v, want the message sent to the DLQ", written)
+	}
+	// The DLQ gets the message as it was consumed, not as it was prepared for the retry topic
+	dlq := written[0]
+	if got := getAttempts(dlq); got != 1 {
+		t.Errorf("DLQ message has attempts %d, want the consumed 1", got)
+	}
+	if got := getHeader(dlq, dueAtHeader); got != "" {
+		t.Errorf("DLQ message has due time %s, want none", got)
+	}
+	if got := getHeader(dlq, originalTopicHeader); got != "" {
+		t.Errorf("DLQ message has original topic %s, want none", got)
+	}
+	if got := getAttempts(msg); got != 1 || len(msg.Headers) != 1 {
+		t.Errorf("consumed message now has headers %v, want them untouched", msg.Headers)
+	}
+}
+
+func TestHandleFailureBothWritesFail(t *testing.T) {
+	p, w := newTestProducer(testKafkaConfig())
+	w.failTopics["job-events.retry.10s"] = true
+	w.failTopics["job-events-dlq"] = true
+
+	if _, err := p.handleFailure(context.Background(), withAttempts(0), errors.New("store unavailable")); err == nil {
+		t.Errorf("handleFailure succeeded although the message could be written nowhere")
+	}
+	if written := w.written(); len(written) != 0 {
+		t.Errorf("handleFailure wrote %v, want nothing", written)
+	}
+}
+
+func TestSendToDLQKeepsOriginalHeaders(t *testing.T) {
+	p, w := newTestProducer(testKafkaConfig())
+	// Spare capacity would let append write the DLQ headers into the consumed message's array
+	orig := withAttempts(2)
+	orig.Headers = append(make([]kafka.Header, 0, 8), orig.Headers...)
+
+	if err := sendToDLQ(context.Background(), p, orig, "boom"); err != nil {
+		t.Fatalf("sendToDLQ: %v", err)
+	}
+	if len(orig.Headers) != 1 || orig.Headers[:2][1].Key != "" {
+		t.Errorf("consumed message headers were written to: %v", orig.Headers[:2])
+	}
+	if written := w.written(); len(written) != 1 || getHeader(written[0], errorHeader) != "boom" {
+		t.Errorf("sendToDLQ wrote %v, want the message with its error reason", written)
+	}
+
+	// Without a DLQ topic the message is dropped
+	p.cfg.DLQTopic = ""
+	if err := sendToDLQ(context.Background(), p, orig, "boom"); err != nil {
+		t.Errorf("sendToDLQ without a DLQ topic: %v", err)
+	}
+	if written := w.written(); len(written) != 1 {
+		t.Errorf("sendToDLQ without a DLQ topic wrote a message")
+	}
+}
+
+func TestWaitUntilDue(t *testing.T) {
+	dueIn := func(d time.Duration) kafka.Message {
+		var msg kafka.Message
+		setHeader(&msg, dueAtHeader, strconv.FormatInt(time.Now().Add(d).UnixMilli(), 10))
+		return msg
+	}
+
+	tests := []struct {
+		name    string
+		msg     kafka.Message
+		wait    time.Duration
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "no due time", msg: kafka.Message{}},
+		{name: "already due", msg: dueIn(-time.Minute)},
+		{name: "due soon", msg: dueIn(150 * time.Millisecond), wait: 100 * time.Millisecond},
+		{name: "cancelled before due", msg: dueIn(time.Minute), timeout: 50 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			err := waitUntilDue(ctx, tt.msg)
+			waited := time.Since(start)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("waitUntilDue returned %v, want error: %v", err, tt.wantErr)
+			}
+			if waited < tt.wait {
+				t.Errorf("waitUntilDue returned after %s, want at least %s", waited, tt.wait)
+			}
+			if waited > tt.wait+time.Second {
+				t.Errorf("waitUntilDue returned after %s, want about %s", waited, tt.wait)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"schedulerservice/internal/config"
+	"schedulerservice/internal/db"
+)
+
+const (
+	// leaseName is the lease held by the instance that schedules jobs
+	leaseName       = "scheduler"
+	defaultLeaseTTL = 15 * time.Second
+)
+
+// Elector competes for the scheduler lease and keeps renewing it while it is the leader
+type Elector struct {
+	// store is nil for a standalone instance, which is always the leader
+	store Store
+	id    string
+	ttl   time.Duration
+
+	mu     sync.Mutex
+	leader bool
+}
+
+// NewElector creates an elector that competes for the lease in store as id. The lease
+// expires ttl after the last renewal, so a dead leader is replaced within ttl
+func NewElector(store Store, id string, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	return &Elector{store: store, id: id, ttl: ttl}
+}
+
+// Standalone creates an elector for a single instance, which is always the leader
+func Standalone(id string) *Elector {
+	return &Elector{id: id}
+}
+
+// NewElectorFromConfig creates an elector keeping its lease in conn when leader election
+// is enabled, and a standalone one otherwise
+func NewElectorFromConfig(conn *sql.DB, dialect db.Dialect, cfg config.Leader) (*Elector, error) {
+	if !cfg.Enabled {
+		return Standalone(cfg.InstanceID), nil
+	}
+
+	store, err := NewSQLStore(conn, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return NewElector(store, cfg.InstanceID, cfg.LeaseTTL.Std()), nil
+}
+
+// ID returns the identity of this instance
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader reports whether this instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+	if e.store == nil {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// Leader returns the identity of the current leader, or an empty string if there is none
+func (e *Elector) Leader(ctx context.Context) (string, error) {
+	if e.store == nil {
+		return e.id, nil
+	}
+	lease, err := e.store.Get(ctx, leaseName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the leader lease: %w", err)
+	}
+	return lease.Holder, nil
+}
+
+// Run competes for the lease until ctx is cancelled, calling onElected when this
+// instance becomes the leader and onDemoted when it stops being the leader
+func (e *Elector) Run(ctx context.Context, onElected, onDemoted func()) {
+	if e.store == nil {
+		log.Printf("[LEADER] Leader election disabled, %s schedules jobs", e.id)
+		onElected()
+		return
+	}
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	var renewed time.Time
+	for {
+		held, err := e.store.Acquire(ctx, leaseName, e.id, e.ttl)
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			log.Printf("[WARN] Failed to renew the leader lease: %v", err)
+			// Step down before the lease can expire and be taken by another instance
+			if e.IsLeader() && time.Since(renewed) >= e.ttl*2/3 {
+				e.setLeader(false, onDemoted)
+			}
+		case held:
+			renewed = time.Now()
+			e.setLeader(true, onElected)
+		default:
+			e.setLeader(false, onDemoted)
+		}
+
+		select {
+		case <-ctx.Done():
+			e.setLeader(false, onDemoted)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Resign gives up the lease so another instance can take over without waiting for it to expire.
+// It is meant for shutdown, once the context passed to Run has been cancelled
+func (e *Elector) Resign(ctx context.Context) error {
+	if e.store == nil {
+		return nil
+	}
+	e.mu.Lock()
+	e.leader = false
+	e.mu.Unlock()
+	return e.store.Release(ctx, leaseName, e.id)
+}
+
+// setLeader records a change of leadership and calls notify if it changed
+func (e *Elector) setLeader(leader bool, notify func()) {
+	e.mu.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	e.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if leader {
+		log.Printf("[LEADER] %s became the leader", e.id)
+	} else {
+		log.Printf("[LEADER] %s is no longer the leader", e.id)
+	}
+	notify()
+}
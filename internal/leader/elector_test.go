@@ -0,0 +1,216 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"schedulerservice/internal/db"
+)
+
+// newTestStore keeps leases in a migrated SQLite database of its own
+func newTestStore(t *testing.T) *SQLStore {
+	t.Helper()
+	conn := migratedSQLite(t)
+	store, err := NewSQLStore(conn.DB(), db.SQLite)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	return store
+}
+
+// migratedSQLite opens a SQLite database in the test's temporary directory with the schema up to date
+func migratedSQLite(t *testing.T) *db.SQLStore {
+	t.Helper()
+	conn, err := db.Open(db.SQLite, filepath.Join(t.TempDir(), "leader.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return conn
+}
+
+// waitFor fails the test if cond is not met within a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewSQLStore(t *testing.T) {
+	conn := migratedSQLite(t)
+	if _, err := NewSQLStore(conn.DB(), db.Dialect("mysql")); err == nil {
+		t.Errorf("NewSQLStore accepted an unknown dialect")
+	}
+
+	empty, err := sql.Open(string(db.SQLite), filepath.Join(t.TempDir(), "empty.db"))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer empty.Close()
+	if _, err := NewSQLStore(empty, db.SQLite); err == nil {
+		t.Errorf("NewSQLStore accepted a database without the leases table")
+	}
+}
+
+func TestSQLStoreLease(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	const ttl = 200 * time.Millisecond
+
+	steps := []struct {
+		name   string
+		holder string
+		want   bool
+	}{
+		{name: "free lease is taken", holder: "a", want: true},
+		{name: "held lease is refused", holder: "b", want: false},
+		{name: "holder renews", holder: "a", want: true},
+	}
+	for _, step := range steps {
+		held, err := store.Acquire(ctx, leaseName, step.holder, ttl)
+		if err != nil {
+			t.Fatalf("%s: Acquire: %v", step.name, err)
+		}
+		if held != step.want {
+			t.Errorf("%s: Acquire by %s = %v, want %v", step.name, step.holder, held, step.want)
+		}
+	}
+	if lease, _ := store.Get(ctx, leaseName); lease.Holder != "a" {
+		t.Errorf("lease is held by %q, want a", lease.Holder)
+	}
+
+	// Once a stops renewing, the lease expires and b takes it over
+	time.Sleep(ttl + 50*time.Millisecond)
+	if lease, _ := store.Get(ctx, leaseName); lease.Holder != "" {
+		t.Errorf("expired lease is held by %q, want nobody", lease.Holder)
+	}
+	if held, err := store.Acquire(ctx, leaseName, "b", time.Minute); err != nil || !held {
+		t.Fatalf("Acquire of the expired lease by b = %v, %v, want it taken", held, err)
+	}
+
+	// Only the holder can release the lease
+	if err := store.Release(ctx, leaseName, "a"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if lease, _ := store.Get(ctx, leaseName); lease.Holder != "b" {
+		t.Errorf("lease is held by %q after a released it, want b", lease.Holder)
+	}
+	if err := store.Release(ctx, leaseName, "b"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if lease, _ := store.Get(ctx, leaseName); lease.Holder != "" {
+		t.Errorf("released lease is held by %q, want nobody", lease.Holder)
+	}
+}
+
+// runElector runs e until the test ends or the returned cancel func is called, counting
+// the elections and demotions
+func runElector(t *testing.T, e *Elector) (elected, demoted *atomic.Int32, cancel func()) {
+	t.Helper()
+	elected, demoted = new(atomic.Int32), new(atomic.Int32)
+	ctx, cancelRun := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, func() { elected.Add(1) }, func() { demoted.Add(1) })
+	}()
+	cancel = func() {
+		cancelRun()
+		<-done
+	}
+	t.Cleanup(cancel)
+	return elected, demoted, cancel
+}
+
+func TestElectorSingleLeader(t *testing.T) {
+	store := newTestStore(t)
+	a := NewElector(store, "a", 300*time.Millisecond)
+	b := NewElector(store, "b", 300*time.Millisecond)
+
+	electedA, _, _ := runElector(t, a)
+	waitFor(t, "a to become the leader", a.IsLeader)
+	electedB, _, _ := runElector(t, b)
+
+	// b keeps competing for a lease a keeps renewing
+	time.Sleep(time.Second)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Errorf("a is leader: %v, b is leader: %v, want only a", a.IsLeader(), b.IsLeader())
+	}
+	if electedA.Load() != 1 || electedB.Load() != 0 {
+		t.Errorf("a was elected %d times and b %d times, want a once", electedA.Load(), electedB.Load())
+	}
+	if leader, err := b.Leader(context.Background()); err != nil || leader != "a" {
+		t.Errorf("b sees %q (%v) as the leader, want a", leader, err)
+	}
+}
+
+func TestElectorTakeoverOnExpiry(t *testing.T) {
+	store := newTestStore(t)
+	const ttl = 300 * time.Millisecond
+	a := NewElector(store, "a", ttl)
+	b := NewElector(store, "b", ttl)
+
+	_, demotedA, stopA := runElector(t, a)
+	waitFor(t, "a to become the leader", a.IsLeader)
+	electedB, _, _ := runElector(t, b)
+
+	// a stops without resigning, so b waits for the lease to expire
+	stopped := time.Now()
+	stopA()
+	if a.IsLeader() || demotedA.Load() != 1 {
+		t.Errorf("stopped elector a is leader: %v and was demoted %d times, want demoted once", a.IsLeader(), demotedA.Load())
+	}
+	waitFor(t, "b to take over", b.IsLeader)
+	if waited := time.Since(stopped); waited < ttl/2 {
+		t.Errorf("b took over %s after a stopped, before the lease could expire", waited)
+	}
+	if electedB.Load() != 1 {
+		t.Errorf("b was elected %d times, want once", electedB.Load())
+	}
+}
+
+func TestElectorResign(t *testing.T) {
+	store := newTestStore(t)
+	// A lease long enough that b can only take over because a resigned
+	const ttl = time.Minute
+	a := NewElector(store, "a", ttl)
+	b := NewElector(store, "b", 300*time.Millisecond)
+
+	_, _, stopA := runElector(t, a)
+	waitFor(t, "a to become the leader", a.IsLeader)
+
+	stopA()
+	if err := a.Resign(context.Background()); err != nil {
+		t.Fatalf("Resign: %v", err)
+	}
+	if leader, _ := a.Leader(context.Background()); leader != "" {
+		t.Errorf("%q is the leader after a resigned, want nobody", leader)
+	}
+
+	runElector(t, b)
+	waitFor(t, "b to take over", b.IsLeader)
+}
+
+func TestStandalone(t *testing.T) {
+	e := Standalone("solo")
+	var elected atomic.Int32
+	e.Run(context.Background(), func() { elected.Add(1) }, func() {})
+	if !e.IsLeader() || elected.Load() != 1 {
+		t.Errorf("standalone elector is leader: %v and was elected %d times, want elected once", e.IsLeader(), elected.Load())
+	}
+	if err := e.Resign(context.Background()); err != nil {
+		t.Errorf("Resign: %v", err)
+	}
+}
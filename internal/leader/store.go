@@ -0,0 +1,93 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"schedulerservice/internal/db"
+)
+
+// Lease is a named lock held by one instance until it expires
+type Lease struct {
+	Name      string    `json:"name"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Store keeps leases in storage shared by every instance of the service
+type Store interface {
+	// Acquire takes the lease for holder if it is free or expired, or renews it if holder
+	// already has it. It reports whether holder owns the lease afterwards
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder owns it
+	Release(ctx context.Context, name, holder string) error
+	// Get returns the current lease, with an empty holder if nobody holds it
+	Get(ctx context.Context, name string) (Lease, error)
+}
+
+// SQLStore keeps leases in the SQLite or Postgres database shared by the instances.
+// Instances sharing a SQLite file, e.g. on a shared volume, rely on its file locking to take turns
+type SQLStore struct {
+	db      *sql.DB
+	dialect db.Dialect
+}
+
+// NewSQLStore keeps leases in the leases table of conn, created by the database migrations.
+// It fails if the dialect is unknown or the table cannot be read
+func NewSQLStore(conn *sql.DB, dialect db.Dialect) (*SQLStore, error) {
+	if dialect != db.SQLite && dialect != db.Postgres {
+		return nil, fmt.Errorf("unsupported database dialect %q", dialect)
+	}
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM leases").Scan(&n); err != nil {
+		return nil, fmt.Errorf("failed to read the leases table: %w", err)
+	}
+	return &SQLStore{db: conn, dialect: dialect}, nil
+}
+
+// Acquire takes or renews the lease in a single upsert, which only overwrites
+// a row held by the same holder or already expired
+func (s *SQLStore) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(`
+        INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+        ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+        WHERE leases.holder = excluded.holder OR leases.expires_at <= ?
+    `), name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Release deletes the lease if holder owns it
+func (s *SQLStore) Release(ctx context.Context, name, holder string) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind("DELETE FROM leases WHERE name = ? AND holder = ?"), name, holder)
+	return err
+}
+
+// Get returns the lease, treating an expired one as free
+func (s *SQLStore) Get(ctx context.Context, name string) (Lease, error) {
+	lease := Lease{Name: name}
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind("SELECT holder, expires_at FROM leases WHERE name = ?"), name).Scan(&lease.Holder, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return lease, nil
+	}
+	if err != nil {
+		return lease, err
+	}
+
+	lease.ExpiresAt = time.UnixMilli(expiresAt).UTC()
+	if !lease.ExpiresAt.After(time.Now()) {
+		return Lease{Name: name}, nil
+	}
+	return lease, nil
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Level is the severity of a log line, given by its tag: [DEBUG], [WARN] or [ERROR].
+// Lines without one of these tags are info
+type Level string
+
+const (
+	Debug Level = "debug"
+	Info  Level = "info"
+	Warn  Level = "warn"
+	Error Level = "error"
+)
+
+var severity = map[Level]int{Debug: 0, Info: 1, Warn: 2, Error: 3}
+
+var tags = []struct {
+	tag   []byte
+	level Level
+}{
+	{[]byte("[DEBUG]"), Debug},
+	{[]byte("[WARN]"), Warn},
+	{[]byte("[ERROR]"), Error},
+}
+
+// ParseLevel checks that level is one of debug, info, warn or error
+func ParseLevel(level string) (Level, error) {
+	if _, ok := severity[Level(level)]; !ok {
+		return "", fmt.Errorf("unknown level %q, must be debug, info, warn or error", level)
+	}
+	return Level(level), nil
+}
+
+// SetLevel makes the standard logger drop the lines below level
+func SetLevel(level Level) {
+	log.SetOutput(&filter{out: os.Stderr, min: severity[level]})
+}
+
+// filter writes the log lines at or above a severity to out
+type filter struct {
+	out io.Writer
+	min int
+}
+
+func (f *filter) Write(line []byte) (int, error) {
+	if severity[lineLevel(line)] < f.min {
+		return len(line), nil
+	}
+	return f.out.Write(line)
+}
+
+// lineLevel finds the tag at the start of the message, after the date and time
+// written by the standard logger
+func lineLevel(line []byte) Level {
+	for _, t := range tags {
+		if i := bytes.Index(line, t.tag); i >= 0 && isPrefix(line[:i]) {
+			return t.level
+		}
+	}
+	return Info
+}
+
+// isPrefix reports whether text only holds the date and time written before a message
+func isPrefix(text []byte) bool {
+	return len(bytes.Trim(text, "0123456789/:. ")) == 0
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// DurationBuckets are the upper bounds of the JobDuration buckets
+var DurationBuckets = prometheus.LinearBuckets(0.1, 0.5, 10)
+
+// durationBucketPrefix starts the names of the stored JobDuration buckets, which end with
+// the upper bound of the bucket, e.g. jobs_execution_duration_bucket{le="0.6"}
+const durationBucketPrefix = string(ExecutionDurationBucket) + `{le="`
+
+// DurationBucket returns the name of the stored bucket counting a run of the given
+// duration, and false if the duration is above every bucket
+func DurationBucket(seconds float64) (MetricName, bool) {
+	i := sort.SearchFloat64s(DurationBuckets, seconds)
+	if i == len(DurationBuckets) {
+		return "", false
+	}
+	return MetricName(fmt.Sprintf("%s%g\"}", durationBucketPrefix, DurationBuckets[i])), true
+}
+
+// ParseDurationBucket returns the upper bound of a bucket named by DurationBucket
+func ParseDurationBucket(name MetricName) (float64, bool) {
+	bound, found := strings.CutPrefix(string(name), durationBucketPrefix)
+	if !found {
+		return 0, false
+	}
+	bound, found = strings.CutSuffix(bound, `"}`)
+	if !found {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(bound, 64)
+	return value, err == nil
+}
+
+// durationCollector exposes JobDuration with the totals restored from the database added
+// to its series, so the histogram carries on from where the previous run of the service left it
+type durationCollector struct {
+	live *prometheus.HistogramVec
+	desc *prometheus.Desc
+
+	mu       sync.Mutex
+	restored map[string]restoredHistogram
+}
+
+// restoredHistogram holds the stored totals of a histogram, with cumulative bucket counts
+type restoredHistogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+var jobDurations = &durationCollector{
+	live:     JobDuration,
+	desc:     prometheus.NewDesc(string(ExecutionDuration), jobDurationHelp, []string{"job_name"}, nil),
+	restored: make(map[string]restoredHistogram),
+}
+
+// RestoreJobDuration sets the stored totals of the duration histogram of a job: the
+// number of runs, the sum of their durations and the number of runs in each bucket, by
+// upper bound. Runs above every bucket are only part of count
+func RestoreJobDuration(jobName string, count uint64, sum float64, buckets map[float64]uint64) {
+	restored := restoredHistogram{count: count, sum: sum, buckets: make(map[float64]uint64, len(DurationBuckets))}
+	var cumulative uint64
+	for _, bound := range DurationBuckets {
+		cumulative += buckets[bound]
+		restored.buckets[bound] = cumulative
+	}
+
+	jobDurations.mu.Lock()
+	defer jobDurations.mu.Unlock()
+	jobDurations.restored[jobName] = restored
+}
+
+// Describe implements prometheus.Collector
+func (c *durationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector, adding the restored totals to the live series
+func (c *durationCollector) Collect(ch chan<- prometheus.Metric) {
+	live := make(chan prometheus.Metric)
+	go func() {
+		c.live.Collect(live)
+		close(live)
+	}()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen := make(map[string]bool, len(c.restored))
+	for metric := range live {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			log.Printf("[WARN] Failed to collect %s: %v", ExecutionDuration, err)
+			continue
+		}
+		jobName := m.GetLabel()[0].GetValue()
+		seen[jobName] = true
+
+		restored := c.restored[jobName]
+		histogram := m.GetHistogram()
+		buckets := make(map[float64]uint64, len(histogram.GetBucket()))
+		for _, b := range histogram.GetBucket() {
+			buckets[b.GetUpperBound()] = b.GetCumulativeCount() + restored.buckets[b.GetUpperBound()]
+		}
+		ch <- prometheus.MustNewConstHistogram(c.desc, histogram.GetSampleCount()+restored.count,
+			histogram.GetSampleSum()+restored.sum, buckets, jobName)
+	}
+
+	for jobName, restored := range c.restored {
+		if !seen[jobName] {
+			ch <- prometheus.MustNewConstHistogram(c.desc, restored.count, restored.sum, restored.buckets, jobName)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gatherDuration returns the duration histogram of a job as exposed by jobDurations, or
+// nil if it has none
+func gatherDuration(t *testing.T, jobName string) *dto.Histogram {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(jobDurations)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if m.GetLabel()[0].GetValue() == jobName {
+				return m.GetHistogram()
+			}
+		}
+	}
+	return nil
+}
+
+// cumulativeCounts returns the cumulative count of each bucket of h, by upper bound
+func cumulativeCounts(h *dto.Histogram) map[float64]uint64 {
+	counts := make(map[float64]uint64, len(h.GetBucket()))
+	for _, b := range h.GetBucket() {
+		counts[b.GetUpperBound()] = b.GetCumulativeCount()
+	}
+	return counts
+}
+
+func TestDurationBucket(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		name    MetricName
+		ok      bool
+	}{
+		{seconds: 0, name: `jobs_execution_duration_bucket{le="0.1"}`, ok: true},
+		{seconds: 0.1, name: `jobs_execution_duration_bucket{le="0.1"}`, ok: true},
+		{seconds: 0.35, name: `jobs_execution_duration_bucket{le="0.6"}`, ok: true},
+		{seconds: 4.6, name: `jobs_execution_duration_bucket{le="4.6"}`, ok: true},
+		{seconds: 4.61},
+		{seconds: 60},
+	}
+	for _, tt := range tests {
+		name, ok := DurationBucket(tt.seconds)
+		if name != tt.name || ok != tt.ok {
+			t.Errorf("DurationBucket(%g) = %s, %v, want %s, %v", tt.seconds, name, ok, tt.name, tt.ok)
+		}
+	}
+}
+
+func TestParseDurationBucket(t *testing.T) {
+	for _, bound := range DurationBuckets {
+		name, ok := DurationBucket(bound)
+		if !ok {
+			t.Fatalf("DurationBucket(%g) found no bucket", bound)
+		}
+		if parsed, ok := ParseDurationBucket(name); !ok || parsed != bound {
+			t.Errorf("ParseDurationBucket(%s) = %g, %v, want %g", name, parsed, ok, bound)
+		}
+	}
+
+	invalid := []MetricName{
+		ExecutionDurationSum,
+		`jobs_execution_duration_bucket{le="0.6"`,
+		`jobs_execution_duration_bucket{le="fast"}`,
+		`jobs_execution_duration_count{le="0.6"}`,
+	}
+	for _, name := range invalid {
+		if bound, ok := ParseDurationBucket(name); ok {
+			t.Errorf("ParseDurationBucket(%s) = %g, want no bucket", name, bound)
+		}
+	}
+}
+
+func TestRestoreJobDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		observe []float64
+		count   uint64
+		sum     float64
+		// buckets are the restored counts of each bucket, not cumulative
+		buckets map[float64]uint64
+		// want are the exposed cumulative counts of a few buckets
+		want map[float64]uint64
+	}{
+		{
+			name:    "restored only",
+			count:   4,
+			sum:     2.5,
+			buckets: map[float64]uint64{0.1: 1, 0.6: 2, 1.1: 1},
+			want:    map[float64]uint64{0.1: 1, 0.6: 3, 1.1: 4, 4.6: 4},
+		},
+		{
+			name:    "restored and live",
+			observe: []float64{0.05, 2},
+			count:   2,
+			sum:     1.2,
+			buckets: map[float64]uint64{0.6: 2},
+			want:    map[float64]uint64{0.1: 1, 0.6: 3, 2.1: 4, 4.6: 4},
+		},
+		{
+			name:    "runs above every bucket",
+			count:   3,
+			sum:     70,
+			buckets: map[float64]uint64{0.1: 1},
+			want:    map[float64]uint64{0.1: 1, 4.6: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobName := "restore " + tt.name
+			for _, seconds := range tt.observe {
+				JobDuration.WithLabelValues(jobName).Observe(seconds)
+			}
+			RestoreJobDuration(jobName, tt.count, tt.sum, tt.buckets)
+
+			h := gatherDuration(t, jobName)
+			if h == nil {
+				t.Fatalf("no duration histogram for %s", jobName)
+			}
+			wantCount := tt.count + uint64(len(tt.observe))
+			wantSum := tt.sum
+			for _, seconds := range tt.observe {
+				wantSum += seconds
+			}
+			if h.GetSampleCount() != wantCount || math.Abs(h.GetSampleSum()-wantSum) > 1e-9 {
+				t.Errorf("histogram has count %d and sum %g, want %d and %g", h.GetSampleCount(), h.GetSampleSum(), wantCount, wantSum)
+			}
+			counts := cumulativeCounts(h)
+			for bound, want := range tt.want {
+				if counts[bound] != want {
+					t.Errorf("bucket le=%g counts %d, want %d", bound, counts[bound], want)
+				}
+			}
+		})
+	}
+}
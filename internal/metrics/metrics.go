@@ -2,7 +2,6 @@ package metrics
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/collectors"
 
 	"sync"
 )
@@ -38,27 +37,104 @@ var (
 		[]string{"job_name"},
 	)
 
+	JobAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: string(TotalAttempts),
+			Help: "Total number of job execution attempts, including retries",
+		},
+		[]string{"job_name", "attempt", "status", "trigger"},
+	)
+
+	JobSkippedRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: string(SkippedRuns),
+			Help: "Total number of job runs skipped by the concurrency policy",
+		},
+		[]string{"job_name"},
+	)
+
+	JobCancelledRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: string(CancelledRuns),
+			Help: "Total number of job runs cancelled before finishing",
+		},
+		[]string{"job_name"},
+	)
+
+	JobMissedRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: string(MissedRuns),
+			Help: "Total number of scheduled job runs missed while the service was down",
+		},
+		[]string{"job_name"},
+	)
+
+	// JobDuration takes the new observations; it is exposed through jobDurations, which
+	// adds the totals restored from the database
 	JobDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    string(ExecutionDuration),
-			Help:    "Job execution time in seconds",
-			Buckets: prometheus.LinearBuckets(0.1, 0.5, 10),
+			Help:    jobDurationHelp,
+			Buckets: DurationBuckets,
 		},
 		[]string{"job_name"},
 	)
+
+	JobHTTPRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: string(HTTPTargetRequests),
+			Help: "Total number of HTTP requests made by jobs, by response status or error kind",
+		},
+		[]string{"job_name", "result"},
+	)
+
+	JobKafkaMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: string(KafkaTargetMessages),
+			Help: "Total number of Kafka messages produced by jobs, by topic and result",
+		},
+		[]string{"job_name", "topic", "result"},
+	)
+
+	KafkaMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: string(KafkaMessagesProcessed),
+			Help: "Total number of Kafka messages processed, by message type and outcome",
+		},
+		[]string{"type", "outcome"},
+	)
+
+	KafkaEventsDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: string(DroppedEvents),
+			Help: "Total number of job and execution events dropped because the publish buffer was full",
+		},
+	)
 )
 
+const jobDurationHelp = "Job execution time in seconds"
+
+var initOnce sync.Once
+
+// Init registers the service metrics with the default Prometheus registry,
+// which already includes the Go and process collectors
 func Init() {
-	sync.OnceFunc(func() {
+	initOnce.Do(func() {
 		prometheus.MustRegister(
 			JobsRegisteredTotal,
 			JobsActive,
 			JobExecutions,
 			JobFailures,
-			JobDuration,
+			JobAttempts,
+			JobSkippedRuns,
+			JobCancelledRuns,
+			JobMissedRuns,
+			jobDurations,
+			JobHTTPRequests,
+			JobKafkaMessages,
+			KafkaMessages,
+			KafkaEventsDropped,
 			Uptime,
-			collectors.NewGoCollector(),
-			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 		)
 	})
 }
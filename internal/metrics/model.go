@@ -8,4 +8,21 @@ const (
 	TotalExecutions   MetricName = "jobs_total_executions"
 	TotalFailures     MetricName = "jobs_total_failures"
 	ExecutionDuration MetricName = "jobs_execution_duration"
+	TotalAttempts     MetricName = "jobs_execution_attempts_total"
+	SkippedRuns       MetricName = "jobs_skipped_runs_total"
+	CancelledRuns     MetricName = "jobs_cancelled_runs_total"
+	MissedRuns        MetricName = "jobs_missed_runs_total"
+
+	// Calls made by the runs, per target type
+	HTTPTargetRequests  MetricName = "jobs_http_requests_total"
+	KafkaTargetMessages MetricName = "jobs_kafka_messages_total"
+
+	KafkaMessagesProcessed MetricName = "kafka_messages_processed_total"
+	DroppedEvents          MetricName = "kafka_events_dropped_total"
+
+	// Sum, count and bucket series of the ExecutionDuration histogram, stored per job.
+	// Each bucket is stored under its own name, see DurationBucket
+	ExecutionDurationSum    MetricName = "jobs_execution_duration_sum"
+	ExecutionDurationCount  MetricName = "jobs_execution_duration_count"
+	ExecutionDurationBucket MetricName = "jobs_execution_duration_bucket"
 )